@@ -35,6 +35,11 @@ var (
 )
 
 func main() {
+	err := c.Load()
+	if err != nil {
+		logger.Fatalf("%v. The bot cannot function. Exiting..", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	sys := make(chan os.Signal, 1)
@@ -24,6 +24,7 @@ package channels
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -41,17 +42,19 @@ func ChannelsConstructor(client *discordgo.Session) *Channels {
 	}
 }
 
-func (c Channels) CreateTextChannel(guildID string, channelData discordgo.GuildChannelCreateData) (*discordgo.Channel, error) {
+func (c Channels) createChannel(guildID string, channelType discordgo.ChannelType, channelData discordgo.GuildChannelCreateData) (*discordgo.Channel, error) {
 	len := len(channelData.Name)
 	if len < 2 || len > 100 {
 		return nil, fmt.Errorf("channel name length must be between 2 and 100 characters. current length: %d", len)
 	}
 
+	channelData.Type = channelType
+
 	channel, err := c.discordClient.GuildChannelCreateComplex(guildID, channelData)
 	if err != nil {
 		errMsg := fmt.Sprintf("Channel creation failed. Error was: %s", err)
 
-		if channel.ID != "" {
+		if channel != nil && channel.ID != "" {
 			_, err := c.discordClient.ChannelDelete(channel.ID)
 			if err != nil {
 				errMsg = fmt.Sprintf("%s. Additionally, the following error occured when reverting changes: %s", errMsg, err)
@@ -64,6 +67,131 @@ func (c Channels) CreateTextChannel(guildID string, channelData discordgo.GuildC
 	return channel, nil
 }
 
+func (c Channels) CreateTextChannel(guildID string, channelData discordgo.GuildChannelCreateData) (*discordgo.Channel, error) {
+	return c.createChannel(guildID, discordgo.ChannelTypeGuildText, channelData)
+}
+
+// CreateVoiceChannel creates a voice channel. Voice channels have no topic, it is ignored by discord.
+func (c Channels) CreateVoiceChannel(guildID string, channelData discordgo.GuildChannelCreateData) (*discordgo.Channel, error) {
+	return c.createChannel(guildID, discordgo.ChannelTypeGuildVoice, channelData)
+}
+
+// CreateStageChannel creates a stage channel. Stage channels are only available in community guilds.
+func (c Channels) CreateStageChannel(guildID string, channelData discordgo.GuildChannelCreateData) (*discordgo.Channel, error) {
+	return c.createChannel(guildID, discordgo.ChannelTypeGuildStageVoice, channelData)
+}
+
+// CreateForumChannel creates a forum channel. Forum channels are only available in community guilds.
+func (c Channels) CreateForumChannel(guildID string, channelData discordgo.GuildChannelCreateData) (*discordgo.Channel, error) {
+	return c.createChannel(guildID, discordgo.ChannelTypeGuildForum, channelData)
+}
+
+// CreateCategory creates a category at the given position with the given permission overwrites
+func (c Channels) CreateCategory(guildID, name string, position int, permissions []*discordgo.PermissionOverwrite) (*discordgo.Channel, error) {
+	return c.createChannel(guildID, discordgo.ChannelTypeGuildCategory, discordgo.GuildChannelCreateData{
+		Name:                 name,
+		Position:             position,
+		PermissionOverwrites: permissions,
+	})
+}
+
+func (c Channels) GetChannelsInCategory(guildID, categoryID string) ([]*discordgo.Channel, error) {
+	var categoryChannels []*discordgo.Channel
+
+	guildChannels, err := c.discordClient.GuildChannels(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, channel := range guildChannels {
+		if channel.ParentID == categoryID {
+			categoryChannels = append(categoryChannels, channel)
+		}
+	}
+
+	return categoryChannels, nil
+}
+
+// GetForumPosts returns the posts of a forum, the open ones followed by the archived ones
+func (c Channels) GetForumPosts(forum *discordgo.Channel) ([]*discordgo.Channel, error) {
+	var posts []*discordgo.Channel
+
+	active, err := c.discordClient.GuildThreadsActive(forum.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, thread := range active.Threads {
+		if thread.ParentID == forum.ID {
+			posts = append(posts, thread)
+		}
+	}
+
+	var before *time.Time
+
+	for {
+		archived, err := c.discordClient.ThreadsArchived(forum.ID, before, 100)
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, archived.Threads...)
+
+		if !archived.HasMore || len(archived.Threads) == 0 {
+			break
+		}
+
+		last := archived.Threads[len(archived.Threads)-1]
+		if last.ThreadMetadata == nil {
+			break
+		}
+
+		before = &last.ThreadMetadata.ArchiveTimestamp
+	}
+
+	return posts, nil
+}
+
+// MoveChannel moves a channel under the given category and replaces its permission overwrites.
+// The channel position is passed along, as the API would otherwise move the channel to the top.
+func (c Channels) MoveChannel(channel *discordgo.Channel, categoryID string, permissions []*discordgo.PermissionOverwrite) (*discordgo.Channel, error) {
+	channelData := discordgo.ChannelEdit{
+		ParentID:             categoryID,
+		Position:             channel.Position,
+		PermissionOverwrites: permissions,
+	}
+
+	movedChannel, err := c.discordClient.ChannelEditComplex(channel.ID, &channelData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to move channel %s: %s", channel.Name, err)
+	}
+
+	return movedChannel, nil
+}
+
+// EditChannel changes the name and topic of a channel. Empty values are left unchanged.
+func (c Channels) EditChannel(channel *discordgo.Channel, name, topic string) (*discordgo.Channel, error) {
+	if name != "" {
+		len := len(name)
+		if len < 2 || len > 100 {
+			return nil, fmt.Errorf("channel name length must be between 2 and 100 characters. current length: %d", len)
+		}
+	}
+
+	channelData := discordgo.ChannelEdit{
+		Name:     name,
+		Topic:    topic,
+		Position: channel.Position,
+	}
+
+	editedChannel, err := c.discordClient.ChannelEditComplex(channel.ID, &channelData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to edit channel %s: %s", channel.Name, err)
+	}
+
+	return editedChannel, nil
+}
+
 func (c Channels) DeleteTextChannel(channelID string) error {
 	_, err := c.discordClient.ChannelDelete(channelID)
 	if err != nil {
@@ -56,6 +56,7 @@ var (
 		"loglevel",
 		"discord_token",
 		"datastore_path",
+		"transcript_path",
 	}
 )
 
@@ -125,6 +126,10 @@ func initDatastore() error {
 	return nil
 }
 
+func initTranscripts() {
+	Configuration.Transcripts.Path = viper.GetString("transcript_path")
+}
+
 func init() {
 
 	// Build config
@@ -135,24 +140,42 @@ func init() {
 
 	// Configure logger
 	initLogging()
+}
+
+// Load opens the datastore and creates the Discord client configured in the environment,
+// and sets up the libraries with them
+func Load() error {
 
 	// Configure datastore
-	err = initDatastore()
+	err := initDatastore()
 	if err != nil {
-		Configuration.Global.Logger.Fatalf("datastore could not be opened: %v. The bot cannot function. Exiting..", err)
+		return fmt.Errorf("datastore could not be opened: %v", err)
 	}
 
+	// Configure transcript export
+	initTranscripts()
+
 	// Configure Discord Client
 	err = initDiscord()
 	if err != nil {
-		Configuration.Global.Logger.Fatalf("no discord client could be created: %v. The bot cannot function. Exiting..", err)
+		return fmt.Errorf("no discord client could be created: %v", err)
 	}
 
+	Setup(Configuration.Discord.Client, Configuration.DataStore.Client)
+
+	return nil
+}
+
+// Setup initialises the libraries with a Discord client and a datastore. Tests use it to
+// run against their own datastore instead of the one configured in the environment.
+func Setup(client *discordgo.Session, db *sql.DB) {
+	Configuration.Discord.Client = client
+	Configuration.DataStore.Client = db
+
 	// init libraries
 	DataStore = d.DataStoreConstructor(Configuration.DataStore.Client)
 	Channels = c.ChannelsConstructor(Configuration.Discord.Client)
 	Roles = r.RolesConstructor(Configuration.Discord.Client)
 	Users = u.UsersConstructor(Configuration.Discord.Client)
 	Messages = m.MessagesConstructor(Configuration.Discord.Client)
-
 }
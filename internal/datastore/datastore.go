@@ -24,6 +24,8 @@ package datastore
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	m "hirohito/internal/models"
@@ -43,13 +45,26 @@ func DataStoreConstructor(client *sql.DB) *DataStore {
 }
 
 func (d DataStore) SetupDatastore(ctx context.Context) error {
-	dsCtx, _ := context.WithTimeout(ctx, 60*time.Second)
-
-	defer dsCtx.Done()
+	dsCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
 
 	sqlStmt := `
 		CREATE TABLE IF NOT EXISTS "guildconfig" ("guildID" TEXT NOT NULL UNIQUE, "joinChannelID" TEXT, "adminChannelID" TEXT, "joinableChannelsCategoryID" TEXT, "anyoneRoleID" TEXT, "adminRoleID" TEXT, "moderatorRoleID" TEXT,  PRIMARY KEY("guildID"));
-		CREATE TABLE IF NOT EXISTS "archiving" ("guildID"	TEXT NOT NULL UNIQUE, "auto" INTEGER NOT NULL DEFAULT 0, "interval"	INTEGER DEFAULT 60, PRIMARY KEY("guildID"));
+		CREATE TABLE IF NOT EXISTS "joinable_channels" ("guildID" TEXT NOT NULL, "channelID" TEXT NOT NULL UNIQUE, "roleID" TEXT NOT NULL, "embedMessageID" TEXT NOT NULL DEFAULT '', "creatorID" TEXT NOT NULL DEFAULT '', "createdAt" INTEGER NOT NULL, "groupName" TEXT NOT NULL DEFAULT '', "requiresApproval" INTEGER NOT NULL DEFAULT 0, "capacity" INTEGER NOT NULL DEFAULT 0, "expiresAt" INTEGER NOT NULL DEFAULT 0, "expiryArchive" INTEGER NOT NULL DEFAULT 0, "expiryReminded" INTEGER NOT NULL DEFAULT 0, PRIMARY KEY("channelID"));
+		CREATE TABLE IF NOT EXISTS "waitlists" ("guildID" TEXT NOT NULL, "channelID" TEXT NOT NULL, "userID" TEXT NOT NULL, "joinedAt" INTEGER NOT NULL, PRIMARY KEY("channelID", "userID"));
+		CREATE TABLE IF NOT EXISTS "joinrequests" ("guildID" TEXT NOT NULL, "channelID" TEXT NOT NULL, "userID" TEXT NOT NULL, "messageID" TEXT NOT NULL, "requestedAt" INTEGER NOT NULL, PRIMARY KEY("channelID", "userID"));
+		CREATE TABLE IF NOT EXISTS "joinprerequisites" ("guildID" TEXT NOT NULL, "channelID" TEXT NOT NULL UNIQUE, "requiredRoleIDs" TEXT NOT NULL DEFAULT '', "minAccountAge" INTEGER NOT NULL DEFAULT 0, "minMemberAge" INTEGER NOT NULL DEFAULT 0, PRIMARY KEY("channelID"));
+		CREATE TABLE IF NOT EXISTS "announcements" ("guildID" TEXT NOT NULL, "channelID" TEXT NOT NULL DEFAULT '', "mode" TEXT NOT NULL DEFAULT '', "logChannelID" TEXT NOT NULL DEFAULT '', "joinTemplate" TEXT NOT NULL DEFAULT '', "leaveTemplate" TEXT NOT NULL DEFAULT '', PRIMARY KEY("guildID", "channelID"));
+		CREATE TABLE IF NOT EXISTS "proposals" ("guildID" TEXT NOT NULL, "channelID" TEXT NOT NULL, "messageID" TEXT NOT NULL UNIQUE, "adminMessageID" TEXT NOT NULL, "name" TEXT NOT NULL, "topic" TEXT NOT NULL, "proposerID" TEXT NOT NULL, "proposedAt" INTEGER NOT NULL, PRIMARY KEY("messageID"));
+		CREATE TABLE IF NOT EXISTS "proposalvotes" ("messageID" TEXT NOT NULL, "userID" TEXT NOT NULL, PRIMARY KEY("messageID", "userID"));
+		CREATE TABLE IF NOT EXISTS "proposalsettings" ("guildID" TEXT NOT NULL UNIQUE, "threshold" INTEGER NOT NULL, "window" INTEGER NOT NULL, PRIMARY KEY("guildID"));
+		CREATE TABLE IF NOT EXISTS "directories" ("guildID" TEXT NOT NULL UNIQUE, "channelID" TEXT NOT NULL, "messageID" TEXT NOT NULL, PRIMARY KEY("guildID"));
+		CREATE TABLE IF NOT EXISTS "channelgroups" ("guildID" TEXT NOT NULL, "name" TEXT NOT NULL, "joinChannelID" TEXT NOT NULL, "directoryMessageID" TEXT NOT NULL DEFAULT '', PRIMARY KEY("guildID", "name"));
+		CREATE TABLE IF NOT EXISTS "groupcategories" ("guildID" TEXT NOT NULL, "groupName" TEXT NOT NULL, "categoryID" TEXT NOT NULL UNIQUE, PRIMARY KEY("categoryID"));
+		CREATE TABLE IF NOT EXISTS "archiving" ("guildID"	TEXT NOT NULL UNIQUE, "auto" INTEGER NOT NULL DEFAULT 0, "interval"	INTEGER DEFAULT 60, "archivingCategoryID" TEXT, "warningDays" INTEGER NOT NULL DEFAULT 7, "retention" INTEGER NOT NULL DEFAULT 0, PRIMARY KEY("guildID"));
+		CREATE TABLE IF NOT EXISTS "archivedchannels" ("guildID" TEXT NOT NULL, "channelID" TEXT NOT NULL UNIQUE, "archivedAt" INTEGER NOT NULL, PRIMARY KEY("channelID"));
+		CREATE TABLE IF NOT EXISTS "archiveoverrides" ("guildID" TEXT NOT NULL, "channelID" TEXT NOT NULL UNIQUE, "exempt" INTEGER NOT NULL DEFAULT 0, "interval" INTEGER NOT NULL DEFAULT 0, PRIMARY KEY("channelID"));
+		CREATE TABLE IF NOT EXISTS "archivewarnings" ("guildID" TEXT NOT NULL, "channelID" TEXT NOT NULL UNIQUE, "messageID" TEXT NOT NULL, "lastActivity" INTEGER NOT NULL, "warnedAt" INTEGER NOT NULL, PRIMARY KEY("channelID"));
 	`
 	tx, err := d.client.BeginTx(dsCtx, nil)
 	if err != nil {
@@ -61,6 +76,52 @@ func (d DataStore) SetupDatastore(ctx context.Context) error {
 		return err
 	}
 
+	// databases created by older versions lack columns that were added later on
+	if err = addColumnIfMissing(tx, "archiving", "archivingCategoryID", "TEXT"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = addColumnIfMissing(tx, "archiving", "warningDays", "INTEGER NOT NULL DEFAULT 7"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = addColumnIfMissing(tx, "archiving", "retention", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = addColumnIfMissing(tx, "joinable_channels", "groupName", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = addColumnIfMissing(tx, "joinable_channels", "requiresApproval", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = addColumnIfMissing(tx, "joinable_channels", "capacity", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = addColumnIfMissing(tx, "joinable_channels", "expiresAt", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = addColumnIfMissing(tx, "joinable_channels", "expiryArchive", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = addColumnIfMissing(tx, "joinable_channels", "expiryReminded", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		tx.Rollback()
 		return err
@@ -68,6 +129,37 @@ func (d DataStore) SetupDatastore(ctx context.Context) error {
 	return nil
 }
 
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%q)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+
+		if name == column {
+			return nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %q ADD COLUMN %q %s", table, column, definition))
+	return err
+}
+
 // Guild config
 func (d DataStore) GetGuildInfo(guildID string) (*m.GuildInformation, error) {
 	var data m.GuildInformation
@@ -107,6 +199,28 @@ func (d DataStore) CreateGuildInfo(guildInfo m.GuildInformation) error {
 	return nil
 }
 
+func (d DataStore) GetAllGuildInfo() ([]m.GuildInformation, error) {
+	var data []m.GuildInformation
+
+	rows, err := d.client.Query("SELECT guildID, joinChannelID, adminChannelID, joinableChannelsCategoryID, anyoneRoleID, adminRoleID, moderatorRoleID FROM guildconfig")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var guildInfo m.GuildInformation
+
+		if err := rows.Scan(&guildInfo.GuildID, &guildInfo.JoinChannelID, &guildInfo.AdminChannelID, &guildInfo.JoinableChannelsCategoryID, &guildInfo.AnyoneRoleID, &guildInfo.AdminRoleID, &guildInfo.ModeratorRoleID); err != nil {
+			return nil, err
+		}
+
+		data = append(data, guildInfo)
+	}
+
+	return data, rows.Err()
+}
+
 func (d DataStore) DeleteGuildInfo(guildID string) error {
 	tx, err := d.client.BeginTx(context.Background(), nil)
 	if err != nil {
@@ -131,34 +245,185 @@ func (d DataStore) DeleteGuildInfo(guildID string) error {
 	return nil
 }
 
-// Archiving
-func (d DataStore) GetArchivingInfo(guildID string) (*m.ArchivingInformation, error) {
-	var data m.ArchivingInformation
+// Joinable channels
+const joinableChannelColumns = "guildID, channelID, roleID, embedMessageID, creatorID, createdAt, groupName, requiresApproval, capacity, expiresAt, expiryArchive, expiryReminded"
 
-	stmt, err := d.client.Prepare("Select guildID, auto, interval, archivingCategoryID FROM archiving WHERE guildID = ?")
+func scanJoinableChannel(row interface{ Scan(...any) error }) (*m.JoinableChannel, error) {
+	var data m.JoinableChannel
+	var createdAt, expiresAt int64
+
+	if err := row.Scan(&data.GuildID, &data.ChannelID, &data.RoleID, &data.EmbedMessageID, &data.CreatorID, &createdAt, &data.GroupName, &data.RequiresApproval, &data.Capacity, &expiresAt, &data.ExpiryArchive, &data.ExpiryReminded); err != nil {
+		return nil, err
+	}
+
+	data.CreatedAt = time.Unix(createdAt, 0)
+
+	if expiresAt > 0 {
+		data.ExpiresAt = time.Unix(expiresAt, 0)
+	}
+
+	return &data, nil
+}
+
+func (d DataStore) GetJoinableChannel(channelID string) (*m.JoinableChannel, error) {
+	stmt, err := d.client.Prepare("SELECT " + joinableChannelColumns + " FROM joinable_channels WHERE channelID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanJoinableChannel(stmt.QueryRow(channelID))
+}
+
+func (d DataStore) GetJoinableChannelByEmbed(messageID string) (*m.JoinableChannel, error) {
+	stmt, err := d.client.Prepare("SELECT " + joinableChannelColumns + " FROM joinable_channels WHERE embedMessageID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanJoinableChannel(stmt.QueryRow(messageID))
+}
+
+func (d DataStore) GetJoinableChannels(guildID string) ([]m.JoinableChannel, error) {
+	var data []m.JoinableChannel
+
+	rows, err := d.client.Query("SELECT "+joinableChannelColumns+" FROM joinable_channels WHERE guildID = ? ORDER BY createdAt", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		joinableChannel, err := scanJoinableChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, *joinableChannel)
+	}
+
+	return data, rows.Err()
+}
+
+// GetExpiringJoinableChannels returns the joinable channels of all guilds that have an expiry time, soonest first
+func (d DataStore) GetExpiringJoinableChannels() ([]m.JoinableChannel, error) {
+	var data []m.JoinableChannel
+
+	rows, err := d.client.Query("SELECT " + joinableChannelColumns + " FROM joinable_channels WHERE expiresAt > 0 ORDER BY expiresAt")
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		joinableChannel, err := scanJoinableChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, *joinableChannel)
+	}
+
+	return data, rows.Err()
+}
+
+func (d DataStore) CreateJoinableChannel(joinableChannel m.JoinableChannel) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO joinable_channels (" + joinableChannelColumns + ") values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	var expiresAt int64
+	if !joinableChannel.ExpiresAt.IsZero() {
+		expiresAt = joinableChannel.ExpiresAt.Unix()
+	}
+
+	if _, err = stmt.Exec(joinableChannel.GuildID, joinableChannel.ChannelID, joinableChannel.RoleID, joinableChannel.EmbedMessageID, joinableChannel.CreatorID, joinableChannel.CreatedAt.Unix(), joinableChannel.GroupName, joinableChannel.RequiresApproval, joinableChannel.Capacity, expiresAt, joinableChannel.ExpiryArchive, joinableChannel.ExpiryReminded); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) DeleteJoinableChannel(channelID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM joinable_channels WHERE channelID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(channelID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Join requests
+const joinRequestColumns = "guildID, channelID, userID, messageID, requestedAt"
 
-	if err := stmt.QueryRow(guildID).Scan(&data.GuildID, &data.Auto, &data.Interval, &data.ArchivingCategoryID); err != nil {
+func scanJoinRequest(row interface{ Scan(...any) error }) (*m.JoinRequest, error) {
+	var data m.JoinRequest
+	var requestedAt int64
+
+	if err := row.Scan(&data.GuildID, &data.ChannelID, &data.UserID, &data.MessageID, &requestedAt); err != nil {
 		return nil, err
 	}
 
+	data.RequestedAt = time.Unix(requestedAt, 0)
+
 	return &data, nil
 }
 
-func (d DataStore) CreateArchivingInfo(archivingInfo m.ArchivingInformation) error {
+func (d DataStore) GetJoinRequest(channelID, userID string) (*m.JoinRequest, error) {
+	stmt, err := d.client.Prepare("SELECT " + joinRequestColumns + " FROM joinrequests WHERE channelID = ? AND userID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanJoinRequest(stmt.QueryRow(channelID, userID))
+}
+
+func (d DataStore) GetJoinRequestByMessage(messageID string) (*m.JoinRequest, error) {
+	stmt, err := d.client.Prepare("SELECT " + joinRequestColumns + " FROM joinrequests WHERE messageID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanJoinRequest(stmt.QueryRow(messageID))
+}
+
+func (d DataStore) CreateJoinRequest(request m.JoinRequest) error {
 	tx, err := d.client.BeginTx(context.Background(), nil)
 	if err != nil {
 		return err
 	}
 
-	stmt, err := tx.Prepare("INSERT OR REPLACE INTO archiving (guildID, auto, interval, archivingCategoryID) values(?, ?, ?, ?)")
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO joinrequests (" + joinRequestColumns + ") values(?, ?, ?, ?, ?)")
 	if err != nil {
 		return err
 	}
 
-	if _, err = stmt.Exec(archivingInfo.GuildID, archivingInfo.Auto, archivingInfo.Interval, archivingInfo.ArchivingCategoryID); err != nil {
+	if _, err = stmt.Exec(request.GuildID, request.ChannelID, request.UserID, request.MessageID, request.RequestedAt.Unix()); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -170,18 +435,1048 @@ func (d DataStore) CreateArchivingInfo(archivingInfo m.ArchivingInformation) err
 	return nil
 }
 
-func (d DataStore) DeleteArchivingInfo(guildID string) error {
+func (d DataStore) DeleteJoinRequest(channelID, userID string) error {
 	tx, err := d.client.BeginTx(context.Background(), nil)
 	if err != nil {
 		return err
 	}
 
-	stmt, err := tx.Prepare("DELETE FROM archiving WHERE guildID = ?")
+	stmt, err := tx.Prepare("DELETE FROM joinrequests WHERE channelID = ? AND userID = ?")
 	if err != nil {
 		return err
 	}
 
-	if _, err = stmt.Exec(guildID); err != nil {
+	if _, err = stmt.Exec(channelID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// DeleteJoinRequests removes all pending requests to join a channel
+func (d DataStore) DeleteJoinRequests(channelID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM joinrequests WHERE channelID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(channelID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Waitlists
+func (d DataStore) GetWaitlist(channelID string) ([]m.WaitlistEntry, error) {
+	var data []m.WaitlistEntry
+
+	rows, err := d.client.Query("SELECT guildID, channelID, userID, joinedAt FROM waitlists WHERE channelID = ? ORDER BY joinedAt, rowid", channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry m.WaitlistEntry
+		var joinedAt int64
+
+		if err := rows.Scan(&entry.GuildID, &entry.ChannelID, &entry.UserID, &joinedAt); err != nil {
+			return nil, err
+		}
+
+		entry.JoinedAt = time.Unix(joinedAt, 0)
+		data = append(data, entry)
+	}
+
+	return data, rows.Err()
+}
+
+func (d DataStore) CreateWaitlistEntry(entry m.WaitlistEntry) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO waitlists (guildID, channelID, userID, joinedAt) values(?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(entry.GuildID, entry.ChannelID, entry.UserID, entry.JoinedAt.Unix()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) DeleteWaitlistEntry(channelID, userID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM waitlists WHERE channelID = ? AND userID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(channelID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// DeleteWaitlist removes the whole waitlist of a channel
+func (d DataStore) DeleteWaitlist(channelID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM waitlists WHERE channelID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(channelID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Join prerequisites
+func (d DataStore) GetJoinPrerequisites(channelID string) (*m.JoinPrerequisites, error) {
+	var data m.JoinPrerequisites
+	var requiredRoleIDs string
+
+	stmt, err := d.client.Prepare("SELECT guildID, channelID, requiredRoleIDs, minAccountAge, minMemberAge FROM joinprerequisites WHERE channelID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stmt.QueryRow(channelID).Scan(&data.GuildID, &data.ChannelID, &requiredRoleIDs, &data.MinAccountAge, &data.MinMemberAge); err != nil {
+		return nil, err
+	}
+
+	if requiredRoleIDs != "" {
+		data.RequiredRoleIDs = strings.Split(requiredRoleIDs, ",")
+	}
+
+	return &data, nil
+}
+
+func (d DataStore) CreateJoinPrerequisites(prerequisites m.JoinPrerequisites) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO joinprerequisites (guildID, channelID, requiredRoleIDs, minAccountAge, minMemberAge) values(?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(prerequisites.GuildID, prerequisites.ChannelID, strings.Join(prerequisites.RequiredRoleIDs, ","), prerequisites.MinAccountAge, prerequisites.MinMemberAge); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) DeleteJoinPrerequisites(channelID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM joinprerequisites WHERE channelID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(channelID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Announcements
+// GetAnnouncementSettings returns the announcement settings of a guild for an empty channelID, or the override of a channel
+func (d DataStore) GetAnnouncementSettings(guildID, channelID string) (*m.AnnouncementSettings, error) {
+	var data m.AnnouncementSettings
+
+	stmt, err := d.client.Prepare("SELECT guildID, channelID, mode, logChannelID, joinTemplate, leaveTemplate FROM announcements WHERE guildID = ? AND channelID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stmt.QueryRow(guildID, channelID).Scan(&data.GuildID, &data.ChannelID, &data.Mode, &data.LogChannelID, &data.JoinTemplate, &data.LeaveTemplate); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+func (d DataStore) CreateAnnouncementSettings(settings m.AnnouncementSettings) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO announcements (guildID, channelID, mode, logChannelID, joinTemplate, leaveTemplate) values(?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(settings.GuildID, settings.ChannelID, settings.Mode, settings.LogChannelID, settings.JoinTemplate, settings.LeaveTemplate); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) DeleteAnnouncementSettings(guildID, channelID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM announcements WHERE guildID = ? AND channelID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(guildID, channelID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// DeleteChannelAnnouncementSettings removes the override of a channel
+func (d DataStore) DeleteChannelAnnouncementSettings(channelID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM announcements WHERE channelID = ? AND channelID != ''")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(channelID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Channel proposals
+const proposalColumns = "guildID, channelID, messageID, adminMessageID, name, topic, proposerID, proposedAt"
+
+func scanChannelProposal(row interface{ Scan(...any) error }) (*m.ChannelProposal, error) {
+	var data m.ChannelProposal
+	var proposedAt int64
+
+	if err := row.Scan(&data.GuildID, &data.ChannelID, &data.MessageID, &data.AdminMessageID, &data.Name, &data.Topic, &data.ProposerID, &proposedAt); err != nil {
+		return nil, err
+	}
+
+	data.ProposedAt = time.Unix(proposedAt, 0)
+
+	return &data, nil
+}
+
+func (d DataStore) GetChannelProposal(messageID string) (*m.ChannelProposal, error) {
+	stmt, err := d.client.Prepare("SELECT " + proposalColumns + " FROM proposals WHERE messageID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanChannelProposal(stmt.QueryRow(messageID))
+}
+
+func (d DataStore) GetChannelProposalByAdminMessage(adminMessageID string) (*m.ChannelProposal, error) {
+	stmt, err := d.client.Prepare("SELECT " + proposalColumns + " FROM proposals WHERE adminMessageID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanChannelProposal(stmt.QueryRow(adminMessageID))
+}
+
+func (d DataStore) GetChannelProposals(guildID string) ([]m.ChannelProposal, error) {
+	rows, err := d.client.Query("SELECT "+proposalColumns+" FROM proposals WHERE guildID = ? ORDER BY proposedAt", guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanChannelProposals(rows)
+}
+
+func (d DataStore) GetAllChannelProposals() ([]m.ChannelProposal, error) {
+	rows, err := d.client.Query("SELECT " + proposalColumns + " FROM proposals ORDER BY proposedAt")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanChannelProposals(rows)
+}
+
+func scanChannelProposals(rows *sql.Rows) ([]m.ChannelProposal, error) {
+	var data []m.ChannelProposal
+	defer rows.Close()
+
+	for rows.Next() {
+		proposal, err := scanChannelProposal(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, *proposal)
+	}
+
+	return data, rows.Err()
+}
+
+func (d DataStore) CreateChannelProposal(proposal m.ChannelProposal) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO proposals (" + proposalColumns + ") values(?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(proposal.GuildID, proposal.ChannelID, proposal.MessageID, proposal.AdminMessageID, proposal.Name, proposal.Topic, proposal.ProposerID, proposal.ProposedAt.Unix()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// DeleteChannelProposal removes a proposal together with its votes
+func (d DataStore) DeleteChannelProposal(messageID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM proposals WHERE messageID = ?", messageID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM proposalvotes WHERE messageID = ?", messageID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// GetProposalVotes returns the IDs of the users who voted for a proposal
+func (d DataStore) GetProposalVotes(messageID string) ([]string, error) {
+	var data []string
+
+	rows, err := d.client.Query("SELECT userID FROM proposalvotes WHERE messageID = ? ORDER BY rowid", messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+
+		data = append(data, userID)
+	}
+
+	return data, rows.Err()
+}
+
+func (d DataStore) CreateProposalVote(messageID, userID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO proposalvotes (messageID, userID) values(?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(messageID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) DeleteProposalVote(messageID, userID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM proposalvotes WHERE messageID = ? AND userID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(messageID, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+func (d DataStore) GetProposalSettings(guildID string) (*m.ProposalSettings, error) {
+	var data m.ProposalSettings
+
+	stmt, err := d.client.Prepare("SELECT guildID, threshold, window FROM proposalsettings WHERE guildID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stmt.QueryRow(guildID).Scan(&data.GuildID, &data.Threshold, &data.Window); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+func (d DataStore) CreateProposalSettings(settings m.ProposalSettings) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO proposalsettings (guildID, threshold, window) values(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(settings.GuildID, settings.Threshold, settings.Window); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// Channel directory
+func (d DataStore) GetChannelDirectory(guildID string) (*m.ChannelDirectory, error) {
+	var data m.ChannelDirectory
+
+	stmt, err := d.client.Prepare("SELECT guildID, channelID, messageID FROM directories WHERE guildID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stmt.QueryRow(guildID).Scan(&data.GuildID, &data.ChannelID, &data.MessageID); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+func (d DataStore) CreateChannelDirectory(directory m.ChannelDirectory) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO directories (guildID, channelID, messageID) values(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(directory.GuildID, directory.ChannelID, directory.MessageID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) DeleteChannelDirectory(guildID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM directories WHERE guildID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(guildID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Channel groups
+func (d DataStore) GetChannelGroup(guildID, name string) (*m.ChannelGroup, error) {
+	var data m.ChannelGroup
+
+	stmt, err := d.client.Prepare("SELECT guildID, name, joinChannelID, directoryMessageID FROM channelgroups WHERE guildID = ? AND name = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stmt.QueryRow(guildID, name).Scan(&data.GuildID, &data.Name, &data.JoinChannelID, &data.DirectoryMessageID); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+func (d DataStore) GetChannelGroups(guildID string) ([]m.ChannelGroup, error) {
+	var data []m.ChannelGroup
+
+	rows, err := d.client.Query("SELECT guildID, name, joinChannelID, directoryMessageID FROM channelgroups WHERE guildID = ? ORDER BY name", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var group m.ChannelGroup
+
+		if err := rows.Scan(&group.GuildID, &group.Name, &group.JoinChannelID, &group.DirectoryMessageID); err != nil {
+			return nil, err
+		}
+
+		data = append(data, group)
+	}
+
+	return data, rows.Err()
+}
+
+func (d DataStore) CreateChannelGroup(group m.ChannelGroup) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO channelgroups (guildID, name, joinChannelID, directoryMessageID) values(?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(group.GuildID, group.Name, group.JoinChannelID, group.DirectoryMessageID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// DeleteChannelGroup removes a group together with its categories
+func (d DataStore) DeleteChannelGroup(guildID, name string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM channelgroups WHERE guildID = ? AND name = ?", guildID, name); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM groupcategories WHERE guildID = ? AND groupName = ?", guildID, name); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+func (d DataStore) GetGroupCategories(guildID string) ([]m.GroupCategory, error) {
+	var data []m.GroupCategory
+
+	rows, err := d.client.Query("SELECT guildID, groupName, categoryID FROM groupcategories WHERE guildID = ? ORDER BY rowid", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var category m.GroupCategory
+
+		if err := rows.Scan(&category.GuildID, &category.GroupName, &category.CategoryID); err != nil {
+			return nil, err
+		}
+
+		data = append(data, category)
+	}
+
+	return data, rows.Err()
+}
+
+func (d DataStore) CreateGroupCategory(category m.GroupCategory) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO groupcategories (guildID, groupName, categoryID) values(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(category.GuildID, category.GroupName, category.CategoryID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) DeleteGroupCategory(categoryID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM groupcategories WHERE categoryID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(categoryID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Archiving
+func (d DataStore) GetArchivingInfo(guildID string) (*m.ArchivingInformation, error) {
+	var data m.ArchivingInformation
+
+	stmt, err := d.client.Prepare("SELECT guildID, auto, interval, IFNULL(archivingCategoryID, ''), warningDays, retention FROM archiving WHERE guildID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stmt.QueryRow(guildID).Scan(&data.GuildID, &data.Auto, &data.Interval, &data.ArchivingCategoryID, &data.WarningDays, &data.Retention); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+func (d DataStore) GetAllArchivingInfo() ([]m.ArchivingInformation, error) {
+	var data []m.ArchivingInformation
+
+	rows, err := d.client.Query("SELECT guildID, auto, interval, IFNULL(archivingCategoryID, ''), warningDays, retention FROM archiving")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var archivingInfo m.ArchivingInformation
+
+		if err := rows.Scan(&archivingInfo.GuildID, &archivingInfo.Auto, &archivingInfo.Interval, &archivingInfo.ArchivingCategoryID, &archivingInfo.WarningDays, &archivingInfo.Retention); err != nil {
+			return nil, err
+		}
+
+		data = append(data, archivingInfo)
+	}
+
+	return data, rows.Err()
+}
+
+func (d DataStore) CreateArchivingInfo(archivingInfo m.ArchivingInformation) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO archiving (guildID, auto, interval, archivingCategoryID, warningDays, retention) values(?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(archivingInfo.GuildID, archivingInfo.Auto, archivingInfo.Interval, archivingInfo.ArchivingCategoryID, archivingInfo.WarningDays, archivingInfo.Retention); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) DeleteArchivingInfo(guildID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM archiving WHERE guildID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(guildID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Archived channels
+func (d DataStore) GetArchivedChannels(guildID string) (map[string]m.ArchivedChannel, error) {
+	data := make(map[string]m.ArchivedChannel)
+
+	rows, err := d.client.Query("SELECT guildID, channelID, archivedAt FROM archivedchannels WHERE guildID = ?", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var archivedChannel m.ArchivedChannel
+		var archivedAt int64
+
+		if err := rows.Scan(&archivedChannel.GuildID, &archivedChannel.ChannelID, &archivedAt); err != nil {
+			return nil, err
+		}
+
+		archivedChannel.ArchivedAt = time.Unix(archivedAt, 0)
+		data[archivedChannel.ChannelID] = archivedChannel
+	}
+
+	return data, rows.Err()
+}
+
+func (d DataStore) CreateArchivedChannel(archivedChannel m.ArchivedChannel) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO archivedchannels (guildID, channelID, archivedAt) values(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(archivedChannel.GuildID, archivedChannel.ChannelID, archivedChannel.ArchivedAt.Unix()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) DeleteArchivedChannel(channelID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM archivedchannels WHERE channelID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(channelID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Archive overrides
+func (d DataStore) GetArchiveOverride(channelID string) (*m.ArchiveOverride, error) {
+	var data m.ArchiveOverride
+
+	stmt, err := d.client.Prepare("SELECT guildID, channelID, exempt, interval FROM archiveoverrides WHERE channelID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stmt.QueryRow(channelID).Scan(&data.GuildID, &data.ChannelID, &data.Exempt, &data.Interval); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+func (d DataStore) GetArchiveOverrides(guildID string) (map[string]m.ArchiveOverride, error) {
+	data := make(map[string]m.ArchiveOverride)
+
+	rows, err := d.client.Query("SELECT guildID, channelID, exempt, interval FROM archiveoverrides WHERE guildID = ?", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var override m.ArchiveOverride
+
+		if err := rows.Scan(&override.GuildID, &override.ChannelID, &override.Exempt, &override.Interval); err != nil {
+			return nil, err
+		}
+
+		data[override.ChannelID] = override
+	}
+
+	return data, rows.Err()
+}
+
+func (d DataStore) CreateArchiveOverride(override m.ArchiveOverride) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO archiveoverrides (guildID, channelID, exempt, interval) values(?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(override.GuildID, override.ChannelID, override.Exempt, override.Interval); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) DeleteArchiveOverride(channelID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM archiveoverrides WHERE channelID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(channelID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+// Archive warnings
+func (d DataStore) GetArchiveWarning(channelID string) (*m.ArchiveWarning, error) {
+	var data m.ArchiveWarning
+	var lastActivity, warnedAt int64
+
+	stmt, err := d.client.Prepare("SELECT guildID, channelID, messageID, lastActivity, warnedAt FROM archivewarnings WHERE channelID = ?")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stmt.QueryRow(channelID).Scan(&data.GuildID, &data.ChannelID, &data.MessageID, &lastActivity, &warnedAt); err != nil {
+		return nil, err
+	}
+
+	data.LastActivity = time.Unix(lastActivity, 0)
+	data.WarnedAt = time.Unix(warnedAt, 0)
+
+	return &data, nil
+}
+
+func (d DataStore) CreateArchiveWarning(warning m.ArchiveWarning) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO archivewarnings (guildID, channelID, messageID, lastActivity, warnedAt) values(?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(warning.GuildID, warning.ChannelID, warning.MessageID, warning.LastActivity.Unix(), warning.WarnedAt.Unix()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (d DataStore) DeleteArchiveWarning(channelID string) error {
+	tx, err := d.client.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("DELETE FROM archivewarnings WHERE channelID = ?")
+	if err != nil {
+		return err
+	}
+
+	if _, err = stmt.Exec(channelID); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -25,7 +25,9 @@ import (
 	"errors"
 	"fmt"
 	m "hirohito/internal/models"
+	"net/http"
 	"regexp"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
 	log "github.com/sirupsen/logrus"
@@ -68,6 +70,29 @@ func FindRoleID(roleIDs []string, roleID string) (int, bool) {
 	return -1, false
 }
 
+// IsUnknownChannel reports whether a request failed because the channel does not exist
+func IsUnknownChannel(err error) bool {
+	return isUnknown(err, discordgo.ErrCodeUnknownChannel)
+}
+
+// IsUnknownRole reports whether a request failed because the role does not exist
+func IsUnknownRole(err error) bool {
+	return isUnknown(err, discordgo.ErrCodeUnknownRole)
+}
+
+func isUnknown(err error, code int) bool {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) {
+		return false
+	}
+
+	if restErr.Message != nil && restErr.Message.Code == code {
+		return true
+	}
+
+	return restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
+
 func FindChannel(channels []*discordgo.Channel, channelName string) (int, bool) {
 	for i, channel := range channels {
 		if channel.Name == channelName {
@@ -77,6 +102,16 @@ func FindChannel(channels []*discordgo.Channel, channelName string) (int, bool)
 	return -1, false
 }
 
+// Truncate shortens a text to the given number of characters, marking the cut with an ellipsis
+func Truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	return string(runes[:length-1]) + "…"
+}
+
 func sendInteraction(s *discordgo.Session, i *discordgo.InteractionCreate, resp *discordgo.InteractionResponse) error {
 	err := s.InteractionRespond(i.Interaction, resp)
 	if err != nil {
@@ -96,6 +131,18 @@ func SendInteractionResponse(s *discordgo.Session, i *discordgo.InteractionCreat
 	return sendInteraction(s, i, &resp)
 }
 
+func SendInteractionEphemeralResponse(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
+	resp := discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}
+
+	return sendInteraction(s, i, &resp)
+}
+
 func SendInteractionAwaitResponse(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
 	resp := discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
@@ -107,6 +154,17 @@ func SendInteractionAwaitResponse(s *discordgo.Session, i *discordgo.Interaction
 	return sendInteraction(s, i, &resp)
 }
 
+func SendInteractionAwaitEphemeralResponse(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	resp := discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	}
+
+	return sendInteraction(s, i, &resp)
+}
+
 func SendInteractionAwaitUpdate(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
 	resp := discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredMessageUpdate,
@@ -118,6 +176,55 @@ func SendInteractionAwaitUpdate(s *discordgo.Session, i *discordgo.InteractionCr
 	return sendInteraction(s, i, &resp)
 }
 
+// SendFollowupEphemeralMessage sends a message only the user can see after the interaction was answered
+func SendFollowupEphemeralMessage(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
+	_, err := s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+		Content: message,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+
+	return err
+}
+
+func SendAutocompleteResponse(s *discordgo.Session, i *discordgo.InteractionCreate, choices []*discordgo.ApplicationCommandOptionChoice) error {
+	resp := discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	}
+
+	return sendInteraction(s, i, &resp)
+}
+
+func EditInteractionResponse(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &message,
+	})
+
+	return err
+}
+
+func EditInteractionResponseComplex(s *discordgo.Session, i *discordgo.InteractionCreate, embeds []*discordgo.MessageEmbed, components []discordgo.MessageComponent) error {
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &embeds,
+		Components: &components,
+	})
+
+	return err
+}
+
+// EditInteractionResponseFiles edits the response like EditInteractionResponseComplex and attaches files to it
+func EditInteractionResponseFiles(s *discordgo.Session, i *discordgo.InteractionCreate, embeds []*discordgo.MessageEmbed, components []discordgo.MessageComponent, files []*discordgo.File) error {
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &embeds,
+		Components: &components,
+		Files:      files,
+	})
+
+	return err
+}
+
 func SendInteractionPingResponse(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	resp := discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponsePong,
@@ -151,6 +258,25 @@ func FindChannelInGuild(d *discordgo.Session, guildID, channelName string) (*dis
 	return guildChannels[pos], nil
 }
 
+// EmbedChannelID extracts the ID of the channel linked in the first field of a join embed
+func EmbedChannelID(message *discordgo.Message) (string, error) {
+	if len(message.Embeds) < 1 || len(message.Embeds[0].Fields) < 1 {
+		return "", errors.New("message has no join embed")
+	}
+
+	exp, err := regexp.Compile(`^<#([0-9]+)>`)
+	if err != nil {
+		return "", err
+	}
+
+	result := exp.FindStringSubmatch(message.Embeds[0].Fields[0].Value)
+	if len(result) < 2 {
+		return "", errors.New("unable to get channelid from embed")
+	}
+
+	return result[1], nil
+}
+
 func FindChannelEmbedMessage(messages []*discordgo.Message, channelName string) (*discordgo.Message, error) {
 
 	exp, err := regexp.Compile(`"(\w.*)"$`)
@@ -171,3 +297,78 @@ func FindChannelEmbedMessage(messages []*discordgo.Message, channelName string)
 
 	return nil, errors.New("no embed found for channel")
 }
+
+// ComponentID builds the custom ID of a message component from the name of its handler and a value
+func ComponentID(name, value string) string {
+	return fmt.Sprintf("%s:%s", name, value)
+}
+
+// ParseComponentID splits the custom ID of a message component into the name of its handler and its value
+func ParseComponentID(customID string) (string, string) {
+	name, value, _ := strings.Cut(customID, ":")
+	return name, value
+}
+
+// Paginate returns the bounds of the requested page in a list of the given length. The page is
+// clamped to the available pages, which are returned as well.
+func Paginate(length, perPage, page int) (start, end, clampedPage, pages int) {
+	pages = (length + perPage - 1) / perPage
+	if pages < 1 {
+		pages = 1
+	}
+
+	if page < 0 {
+		page = 0
+	}
+	if page >= pages {
+		page = pages - 1
+	}
+
+	start = page * perPage
+	end = start + perPage
+	if end > length {
+		end = length
+	}
+
+	return start, end, page, pages
+}
+
+// PageButtons returns the previous and next buttons of a paginated message.
+// The custom ID of each button carries the page it leads to.
+func PageButtons(name string, page, pages int) discordgo.ActionsRow {
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Previous",
+				Style:    discordgo.SecondaryButton,
+				CustomID: ComponentID(name, fmt.Sprint(page-1)),
+				Disabled: page <= 0,
+			},
+			discordgo.Button{
+				Label:    "Next",
+				Style:    discordgo.SecondaryButton,
+				CustomID: ComponentID(name, fmt.Sprint(page+1)),
+				Disabled: page >= pages-1,
+			},
+		},
+	}
+}
+
+// ConfirmButtons returns the buttons asking to confirm or cancel an action.
+// The custom ID of each button carries the choice, either "confirm" or "cancel".
+func ConfirmButtons(name, label string) discordgo.ActionsRow {
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    label,
+				Style:    discordgo.DangerButton,
+				CustomID: ComponentID(name, "confirm"),
+			},
+			discordgo.Button{
+				Label:    "Cancel",
+				Style:    discordgo.SecondaryButton,
+				CustomID: ComponentID(name, "cancel"),
+			},
+		},
+	}
+}
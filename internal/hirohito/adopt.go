@@ -0,0 +1,248 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// adoptableRole reports why a role can not give access to an adopted channel, or an empty string when it can
+func adoptableRole(guildInfo *m.GuildInformation, role *discordgo.Role) (string, error) {
+	switch {
+	case role.ID == guildInfo.GuildID || role.ID == guildInfo.AnyoneRoleID:
+		return "Everyone can not be the role of a joinable channel.", nil
+	case role.ID == guildInfo.AdminRoleID || role.ID == guildInfo.ModeratorRoleID:
+		return "The admin and moderator roles can not be the role of a joinable channel.", nil
+	case role.Managed:
+		return fmt.Sprintf("%s is managed by an integration and can not be assigned by the bot.", role.Mention()), nil
+	}
+
+	records, err := joinableChannelRecords(guildInfo.GuildID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, record := range records {
+		if record.RoleID == role.ID {
+			return fmt.Sprintf("%s already belongs to the joinable channel <#%s>.", role.Mention(), record.ChannelID), nil
+		}
+	}
+
+	return "", nil
+}
+
+// adoptJoinableChannel registers an existing channel as a joinable channel of a group. The permission overwrites
+// are replaced by the joinable layout and the channel is moved when it is outside the categories of the group.
+// The messages of the channel are left alone. When the adoption fails halfway, the record is removed and the
+// previous category and permission overwrites are restored, so the adoption can simply be retried. The adopted
+// channel is still returned with the error when the adoption could not be undone.
+func adoptJoinableChannel(guildInfo *m.GuildInformation, group *m.ChannelGroup, channel *discordgo.Channel, role *discordgo.Role, adopterID string) (*discordgo.Channel, error) {
+	categories, err := joinableCategories(guildInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	categoryID := channel.ParentID
+
+	if groupName, found := categories[categoryID]; !found || groupName != group.Name {
+		categoryID, err = groupCategory(guildInfo, group)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	adoptedChannel, err := c.Channels.MoveChannel(channel, categoryID, joinablePermissions(guildInfo, role.ID, channel.Type))
+	if err != nil {
+		return nil, err
+	}
+
+	joinableChannel := m.JoinableChannel{
+		GuildID:   guildInfo.GuildID,
+		ChannelID: adoptedChannel.ID,
+		RoleID:    role.ID,
+		CreatorID: adopterID,
+		CreatedAt: time.Now(),
+		GroupName: group.Name,
+	}
+
+	// the channel is recorded before it is announced, the channel directory only lists recorded channels
+	err = c.DataStore.CreateJoinableChannel(joinableChannel)
+	if err != nil {
+		restoreErr := restoreChannel(channel)
+		if restoreErr != nil {
+			return adoptedChannel, fmt.Errorf("unable to record adopted channel: %s. The channel could not be restored either, put back its category and permissions by hand: %s", err, restoreErr)
+		}
+		return nil, fmt.Errorf("unable to record adopted channel, the channel was restored: %s", err)
+	}
+
+	joinableChannel.EmbedMessageID, err = announceJoinableChannel(guildInfo, group, adoptedChannel)
+	if err != nil {
+		// without its record the channel can only be restored, otherwise /repair posts the missing join embed
+		deleteErr := c.DataStore.DeleteJoinableChannel(adoptedChannel.ID)
+		if deleteErr != nil {
+			logger.Errorf("unable to remove record of channel %s after a failed adoption: %s", channel.Name, deleteErr)
+			return adoptedChannel, fmt.Errorf("channel adopted, but its join embed could not be posted. Use /repair to post it: %s", err)
+		}
+
+		restoreErr := restoreChannel(channel)
+		if restoreErr != nil {
+			return adoptedChannel, fmt.Errorf("unable to announce adopted channel: %s. The channel could not be restored either, put back its category and permissions by hand: %s", err, restoreErr)
+		}
+		return nil, fmt.Errorf("unable to announce adopted channel, the adoption was undone: %s", err)
+	}
+
+	err = c.DataStore.CreateJoinableChannel(joinableChannel)
+	if err != nil {
+		return adoptedChannel, fmt.Errorf("channel adopted, but its join embed could not be recorded. Use /repair to record it: %s", err)
+	}
+
+	return adoptedChannel, nil
+}
+
+// restoreChannel puts back the category and permission overwrites a channel had before a failed adoption
+func restoreChannel(channel *discordgo.Channel) error {
+	_, err := c.Channels.MoveChannel(channel, channel.ParentID, channel.PermissionOverwrites)
+	if err != nil {
+		logger.Errorf("unable to restore channel %s after a failed adoption: %s", channel.Name, err)
+	}
+
+	return err
+}
+
+func adoptChannel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var channelID, groupName string
+	var role *discordgo.Role
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	data := i.ApplicationCommandData()
+
+	for _, option := range data.Options {
+		switch option.Name {
+		case "channel":
+			channelID = option.Value.(string)
+		case "role":
+			role = data.Resolved.Roles[option.Value.(string)]
+		case "group":
+			groupName = strings.ToLower(option.StringValue())
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	channel, err := s.Channel(channelID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, fmt.Sprintf("unable to retrieve channel: %s", err))
+		return
+	}
+
+	if _, found := joinableChannelTypes[channel.Type]; !found {
+		h.SendInteractionResponse(s, i, "Only text, voice, stage and forum channels can be made joinable.")
+		return
+	}
+
+	if channel.ID == guildInfo.JoinChannelID || channel.ID == guildInfo.AdminChannelID {
+		h.SendInteractionResponse(s, i, "The join and admin channels can not be made joinable.")
+		return
+	}
+
+	_, err = c.DataStore.GetJoinableChannel(channel.ID)
+	if err == nil {
+		h.SendInteractionResponse(s, i, fmt.Sprintf("%s is already a joinable channel.", channel.Mention()))
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	group, err := channelGroup(guildInfo, groupName)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	if role != nil {
+		reason, err := adoptableRole(guildInfo, role)
+		if err != nil {
+			h.SendInteractionResponse(s, i, err.Error())
+			return
+		}
+
+		if reason != "" {
+			h.SendInteractionResponse(s, i, reason)
+			return
+		}
+	}
+
+	// moving the channel and posting the join embed takes a while
+	err = h.SendInteractionAwaitResponse(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	createdRole := role == nil
+	if createdRole {
+		role, err = createChannelRole(i.GuildID, channel.Name)
+		if err != nil {
+			h.EditInteractionResponse(s, i, err.Error())
+			return
+		}
+	}
+
+	adoptedChannel, err := adoptJoinableChannel(guildInfo, group, channel, role, i.Member.User.ID)
+	if err != nil {
+		// a role created for this adoption would be left without a channel. When the adoption could not be
+		// undone, the channel still depends on the role and it is kept.
+		if createdRole && adoptedChannel == nil {
+			if deleteErr := c.Roles.DeleteRole(i.GuildID, role.ID); deleteErr != nil {
+				logger.Errorf("unable to delete role %s after a failed adoption: %s", role.Name, deleteErr)
+			}
+		}
+
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	h.EditInteractionResponse(s, i, fmt.Sprintf("Channel adopted: %v, members of the %s role can see it.", adoptedChannel.Mention(), role.Name))
+}
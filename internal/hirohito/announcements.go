@@ -0,0 +1,328 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	"hirohito/internal/messages"
+	m "hirohito/internal/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Announcement modes
+const (
+	announceInChannel = "channel"
+	announceToLog     = "log"
+	announceOff       = "off"
+)
+
+const (
+	defaultJoinTemplate  = "▶️ User {user} joined {channel}!"
+	defaultLeaveTemplate = "🚮 User {user} left {channel}!"
+)
+
+// memberCounts caches the number of members per role of every guild. Counting walks through every member
+// of a guild, which is too slow to repeat on every join and leave. In between counts the cache follows the
+// joins and leaves the bot handles itself.
+var memberCounts = struct {
+	sync.Mutex
+	guilds map[string]countedMembers
+}{guilds: make(map[string]countedMembers)}
+
+type countedMembers struct {
+	counts    map[string]int
+	countedAt time.Time
+}
+
+// cachedMemberCount returns the number of members of a role after a member joined (change 1) or left (change -1).
+// The guild is counted again when its counts are older than memberCountTTL.
+func cachedMemberCount(guildID, roleID string, change int) (int, error) {
+	memberCounts.Lock()
+	defer memberCounts.Unlock()
+
+	cached, found := memberCounts.guilds[guildID]
+	if found && time.Since(cached.countedAt) < memberCountTTL {
+		cached.counts[roleID] += change
+		if cached.counts[roleID] < 0 {
+			cached.counts[roleID] = 0
+		}
+		return cached.counts[roleID], nil
+	}
+
+	// a fresh count already includes the change
+	counts, err := c.Users.CountRoleMembers(guildID)
+	if err != nil {
+		return 0, err
+	}
+
+	memberCounts.guilds[guildID] = countedMembers{counts: counts, countedAt: time.Now()}
+
+	return counts[roleID], nil
+}
+
+// mergeAnnouncementSettings overrides the settings with the fields that are set in the override
+func mergeAnnouncementSettings(settings *m.AnnouncementSettings, override *m.AnnouncementSettings) {
+	if override.Mode != "" {
+		settings.Mode = override.Mode
+	}
+
+	if override.LogChannelID != "" {
+		settings.LogChannelID = override.LogChannelID
+	}
+
+	if override.JoinTemplate != "" {
+		settings.JoinTemplate = override.JoinTemplate
+	}
+
+	if override.LeaveTemplate != "" {
+		settings.LeaveTemplate = override.LeaveTemplate
+	}
+}
+
+// storedAnnouncementSettings returns the stored settings of a guild or the override of a channel, or empty settings
+func storedAnnouncementSettings(guildID, channelID string) (*m.AnnouncementSettings, error) {
+	settings, err := c.DataStore.GetAnnouncementSettings(guildID, channelID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		settings = &m.AnnouncementSettings{
+			GuildID:   guildID,
+			ChannelID: channelID,
+		}
+	}
+
+	return settings, nil
+}
+
+// announcementSettings returns the settings that apply to a channel: the defaults, overridden by the
+// settings of the guild, overridden by the settings of the channel. An empty channelID skips the last step.
+func announcementSettings(guildID, channelID string) (*m.AnnouncementSettings, error) {
+	settings := m.AnnouncementSettings{
+		GuildID:       guildID,
+		ChannelID:     channelID,
+		Mode:          announceInChannel,
+		JoinTemplate:  defaultJoinTemplate,
+		LeaveTemplate: defaultLeaveTemplate,
+	}
+
+	guildSettings, err := storedAnnouncementSettings(guildID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	mergeAnnouncementSettings(&settings, guildSettings)
+
+	if channelID == "" {
+		return &settings, nil
+	}
+
+	channelSettings, err := storedAnnouncementSettings(guildID, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeAnnouncementSettings(&settings, channelSettings)
+
+	return &settings, nil
+}
+
+// announceMembership posts the join or leave announcement of a channel according to its settings.
+// Failures are only logged, the membership itself already changed.
+func announceMembership(guildInfo *m.GuildInformation, user *discordgo.User, channel *discordgo.Channel, joinableChannel *m.JoinableChannel, joined bool) {
+	settings, err := announcementSettings(guildInfo.GuildID, channel.ID)
+	if err != nil {
+		logger.Errorf("unable to retrieve announcement settings of channel %s: %s", channel.Name, err)
+		return
+	}
+
+	template := settings.LeaveTemplate
+	if joined {
+		template = settings.JoinTemplate
+	}
+
+	targetChannelID := channel.ID
+
+	switch settings.Mode {
+	case announceOff:
+		return
+	case announceToLog:
+		if settings.LogChannelID == "" {
+			logger.Warnf("no log channel configured for the announcements of channel %s", channel.Name)
+			return
+		}
+		targetChannelID = settings.LogChannelID
+	default:
+		// nothing can be posted in a forum itself
+		if channel.Type == discordgo.ChannelTypeGuildForum {
+			return
+		}
+	}
+
+	var members int
+
+	// members are only counted when the template needs it
+	if strings.Contains(template, messages.PlaceholderMembers) {
+		change := -1
+		if joined {
+			change = 1
+		}
+
+		members, err = cachedMemberCount(guildInfo.GuildID, joinableChannel.RoleID, change)
+		if err != nil {
+			logger.Warnf("unable to count members of channel %s: %s", channel.Name, err)
+		}
+	}
+
+	if joined {
+		err = c.Messages.UserJoinedChannelMessage(targetChannelID, template, *user, channel, members)
+	} else {
+		err = c.Messages.UserLeftChannelMessage(targetChannelID, template, *user, channel, members)
+	}
+	if err != nil {
+		logger.Warnf("unable to announce membership change of channel %s: %s", channel.Name, err)
+	}
+}
+
+func announcementSettingsSummary(settings *m.AnnouncementSettings) string {
+	logChannel := "not set"
+	if settings.LogChannelID != "" {
+		logChannel = fmt.Sprintf("<#%s>", settings.LogChannelID)
+	}
+
+	return fmt.Sprintf("mode: %s\nlog channel: %s\njoin template: %s\nleave template: %s\nplaceholders: %s, %s, %s, %s",
+		settings.Mode, logChannel, settings.JoinTemplate, settings.LeaveTemplate,
+		messages.PlaceholderUser, messages.PlaceholderUsername, messages.PlaceholderChannel, messages.PlaceholderMembers)
+}
+
+func announcementsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name, channelID, target string
+	var changed bool
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "channelname" {
+			name = option.StringValue()
+		}
+	}
+
+	target = "this server"
+
+	if name != "" {
+		channel, _, err := joinableChannelByName(s, i.GuildID, name)
+		if err != nil {
+			h.SendInteractionResponse(s, i, err.Error())
+			return
+		}
+
+		channelID = channel.ID
+		target = channel.Mention()
+	}
+
+	stored, err := storedAnnouncementSettings(i.GuildID, channelID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	// "default" clears a setting, so the setting of the guild or the default applies again
+	cleared := func(value string) string {
+		if strings.EqualFold(value, "default") {
+			return ""
+		}
+		return value
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "channelname":
+			continue
+		case "mode":
+			stored.Mode = cleared(option.StringValue())
+		case "logchannel":
+			stored.LogChannelID = option.Value.(string)
+		case "clearlogchannel":
+			if !option.BoolValue() {
+				continue
+			}
+			stored.LogChannelID = ""
+		case "jointemplate":
+			stored.JoinTemplate = cleared(option.StringValue())
+		case "leavetemplate":
+			stored.LeaveTemplate = cleared(option.StringValue())
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+		changed = true
+	}
+
+	if changed {
+		if stored.Mode == "" && stored.LogChannelID == "" && stored.JoinTemplate == "" && stored.LeaveTemplate == "" {
+			err = c.DataStore.DeleteAnnouncementSettings(i.GuildID, channelID)
+		} else {
+			err = c.DataStore.CreateAnnouncementSettings(*stored)
+		}
+		if err != nil {
+			h.SendInteractionResponse(s, i, err.Error())
+			return
+		}
+	}
+
+	settings, err := announcementSettings(i.GuildID, channelID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	summary := fmt.Sprintf("Announcements of %s:\n%s", target, announcementSettingsSummary(settings))
+
+	if settings.Mode == announceToLog && settings.LogChannelID == "" {
+		summary = fmt.Sprintf("%s\nAnnouncements are not sent until a log channel is set with the logchannel option.", summary)
+	}
+
+	if changed {
+		summary = fmt.Sprintf("Announcement settings saved.\n%s", summary)
+	}
+
+	h.SendInteractionResponse(s, i, summary)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	m "hirohito/internal/models"
+	"testing"
+)
+
+func TestMergeAnnouncementSettings(t *testing.T) {
+	base := m.AnnouncementSettings{
+		GuildID:       "guild",
+		Mode:          announceInChannel,
+		JoinTemplate:  defaultJoinTemplate,
+		LeaveTemplate: defaultLeaveTemplate,
+	}
+
+	tests := []struct {
+		name     string
+		override m.AnnouncementSettings
+		want     m.AnnouncementSettings
+	}{
+		{
+			name: "empty override",
+			want: base,
+		},
+		{
+			name:     "mode and log channel",
+			override: m.AnnouncementSettings{Mode: announceToLog, LogChannelID: "log"},
+			want: m.AnnouncementSettings{
+				GuildID: "guild", Mode: announceToLog, LogChannelID: "log",
+				JoinTemplate: defaultJoinTemplate, LeaveTemplate: defaultLeaveTemplate,
+			},
+		},
+		{
+			name:     "templates only",
+			override: m.AnnouncementSettings{JoinTemplate: "hi {user}", LeaveTemplate: "bye {user}"},
+			want: m.AnnouncementSettings{
+				GuildID: "guild", Mode: announceInChannel,
+				JoinTemplate: "hi {user}", LeaveTemplate: "bye {user}",
+			},
+		},
+		{
+			name:     "identifiers are kept",
+			override: m.AnnouncementSettings{GuildID: "other", ChannelID: "channel", Mode: announceOff},
+			want: m.AnnouncementSettings{
+				GuildID: "guild", Mode: announceOff,
+				JoinTemplate: defaultJoinTemplate, LeaveTemplate: defaultLeaveTemplate,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := base
+			mergeAnnouncementSettings(&got, &tt.override)
+
+			if got != tt.want {
+				t.Errorf("mergeAnnouncementSettings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// pendingJoinRequest returns the pending request of a user to join a channel, or nil when there is none
+func pendingJoinRequest(channelID, userID string) (*m.JoinRequest, error) {
+	request, err := c.DataStore.GetJoinRequest(channelID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// requestJoin asks the moderators in the admin channel to approve a user joining a channel
+func requestJoin(guildInfo *m.GuildInformation, user *discordgo.User, channel *discordgo.Channel) (string, error) {
+	request, err := pendingJoinRequest(channel.ID, user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if request != nil {
+		return fmt.Sprintf("Your request to join %s is still waiting for a moderator.", channel.Mention()), nil
+	}
+
+	message, err := c.Messages.JoinRequestMessage(guildInfo.AdminChannelID, user, channel)
+	if err != nil {
+		return "", fmt.Errorf("unable to post join request for channel %s: %s", channel.Name, err)
+	}
+
+	err = c.DataStore.CreateJoinRequest(m.JoinRequest{
+		GuildID:     guildInfo.GuildID,
+		ChannelID:   channel.ID,
+		UserID:      user.ID,
+		MessageID:   message.ID,
+		RequestedAt: time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Joining %s requires approval. Your request was sent to the moderators, you will get a DM with their decision.", channel.Mention()), nil
+}
+
+// withdrawJoinRequest cancels the pending request of a user who leaves a channel they did not join yet
+func withdrawJoinRequest(guildInfo *m.GuildInformation, user *discordgo.User, channel *discordgo.Channel) (string, error) {
+	request, err := pendingJoinRequest(channel.ID, user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if request == nil {
+		return fmt.Sprintf("You are not a member of %s.", channel.Mention()), nil
+	}
+
+	err = c.DataStore.DeleteJoinRequest(channel.ID, user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	err = c.Messages.DeleteMessage(guildInfo.AdminChannelID, request.MessageID)
+	if err != nil {
+		logger.Warnf("unable to delete withdrawn join request for channel %s: %s", channel.Name, err)
+	}
+
+	return fmt.Sprintf("You withdrew your request to join %s.", channel.Mention()), nil
+}
+
+// decideJoinRequest carries out the decision of a moderator and returns the outcome for the admin channel
+func decideJoinRequest(s *discordgo.Session, guildInfo *m.GuildInformation, request *m.JoinRequest, moderator *discordgo.User, approved bool) (string, error) {
+	channel, err := s.Channel(request.ChannelID)
+	if err != nil {
+		return "The channel no longer exists.", nil
+	}
+
+	user, err := s.User(request.UserID)
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve user %s: %s", request.UserID, err)
+	}
+
+	decision := fmt.Sprintf("Denied by %s.", moderator.Mention())
+	notification := fmt.Sprintf("Your request to join #%s was denied by the moderators.", channel.Name)
+
+	if approved {
+		joinableChannel, err := joinableChannelRecord(channel)
+		if err != nil {
+			return "", err
+		}
+
+		// a full channel puts the approved user on its waitlist
+		result, err := admitChannelMember(guildInfo, user, channel, joinableChannel)
+		if err != nil {
+			return "", err
+		}
+
+		decision = fmt.Sprintf("Approved by %s.", moderator.Mention())
+		notification = fmt.Sprintf("Your request to join #%s was approved. %s", channel.Name, result)
+	}
+
+	err = c.Messages.SendDirectMessage(user.ID, notification)
+	if err != nil {
+		logger.Warnf("unable to send join request decision to user %s: %s", user.ID, err)
+		decision = fmt.Sprintf("%s The user could not be notified.", decision)
+	}
+
+	return decision, nil
+}
+
+func joinRequestButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionEphemeralResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	request, err := c.DataStore.GetJoinRequestByMessage(i.Message.ID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, "This request was already handled or withdrawn.")
+		return
+	}
+
+	_, choice := h.ParseComponentID(i.MessageComponentData().CustomID)
+
+	// admitting the user counts the members of the channel, which can take longer than Discord waits for a response
+	err = h.SendInteractionAwaitUpdate(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	decision, err := decideJoinRequest(s, guildInfo, request, i.Member.User, choice == "approve")
+	if err != nil {
+		logger.Error(err)
+		h.SendFollowupEphemeralMessage(s, i, "Something went wrong, the request is still pending.")
+		return
+	}
+
+	err = c.DataStore.DeleteJoinRequest(request.ChannelID, request.UserID)
+	if err != nil {
+		logger.Errorf("unable to delete join request of user %s for channel %s: %s", request.UserID, request.ChannelID, err)
+	}
+
+	err = c.Messages.DecideJoinRequest(i.Message, decision)
+	if err != nil {
+		logger.Errorf("unable to record decision on join request: %s", err)
+	}
+}
+
+func channelApproval(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name string
+	var required bool
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "channelname":
+			name = option.StringValue()
+		case "required":
+			required = option.BoolValue()
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	channel, joinableChannel, err := joinableChannelByName(s, i.GuildID, name)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	joinableChannel.RequiresApproval = 0
+	if required {
+		joinableChannel.RequiresApproval = 1
+	}
+
+	err = c.DataStore.CreateJoinableChannel(*joinableChannel)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	if required {
+		h.SendInteractionResponse(s, i, fmt.Sprintf("Joining %s now requires approval by a moderator.", channel.Mention()))
+		return
+	}
+
+	h.SendInteractionResponse(s, i, fmt.Sprintf("%s can be joined without approval again. Pending requests can still be handled.", channel.Mention()))
+}
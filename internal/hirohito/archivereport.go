@@ -0,0 +1,234 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const archiveReportPageSize = 10
+
+type archiveReportEntry struct {
+	channel  *discordgo.Channel
+	activity time.Time
+	members  int
+	verdict  string
+}
+
+// archiveReport evaluates every joinable channel the way the archiver would, without acting on it
+func archiveReport(now time.Time, guildInfo *m.GuildInformation, archivingInfo *m.ArchivingInformation) ([]archiveReportEntry, error) {
+	var report []archiveReportEntry
+
+	channels, err := joinableChannels(guildInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := c.DataStore.GetArchiveOverrides(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := joinableChannelRecords(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	memberCounts, err := c.Users.CountRoleMembers(guildInfo.GuildID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to count channel members: %s", err)
+	}
+
+	for _, channel := range channels {
+		// forums are never archived by the archiver, so they are left out of the report as well
+		if channel.Type == discordgo.ChannelTypeGuildForum {
+			continue
+		}
+
+		entry := archiveReportEntry{channel: channel}
+
+		entry.members = memberCounts[records[channel.ID].RoleID]
+
+		entry.activity, err = lastActivity(channel)
+		if err != nil {
+			return nil, err
+		}
+
+		var override *m.ArchiveOverride
+		if o, found := overrides[channel.ID]; found {
+			override = &o
+		}
+
+		channelInfo, archivable := channelArchivingInfo(archivingInfo, override)
+		if !archivable {
+			entry.verdict = "exempt"
+			report = append(report, entry)
+			continue
+		}
+
+		warning, valid, err := pendingWarning(channel, entry.activity)
+		if err != nil {
+			return nil, err
+		}
+
+		if !valid {
+			warning = nil
+		}
+		if warning != nil {
+			entry.activity = warning.LastActivity
+		}
+
+		switch nextArchiveAction(now, entry.activity, channelInfo, warning) {
+		case archiveActionArchive:
+			entry.verdict = "**archive**"
+		case archiveActionWarn:
+			entry.verdict = "**warn**"
+		default:
+			entry.verdict = "keep"
+			if warning != nil {
+				entry.verdict = "keep, warning pending"
+			}
+		}
+
+		report = append(report, entry)
+	}
+
+	return report, nil
+}
+
+func archiveReportPage(guildInfo *m.GuildInformation, page int) ([]*discordgo.MessageEmbed, []discordgo.MessageComponent, error) {
+	archivingInfo, err := c.DataStore.GetArchivingInfo(guildInfo.GuildID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, errors.New(`this guild has no archiving settings. use the "archivesettings" command to configure them`)
+		}
+		return nil, nil, err
+	}
+
+	report, err := archiveReport(time.Now(), guildInfo, archivingInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start, end, page, pages := h.Paginate(len(report), archiveReportPageSize, page)
+
+	var lines []string
+	for _, entry := range report[start:end] {
+		lines = append(lines, fmt.Sprintf("%s · last activity <t:%d:R> · %d members · %s", entry.channel.Mention(), entry.activity.Unix(), entry.members, entry.verdict))
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "There are no joinable channels.")
+	}
+
+	embed := discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Archive report (page %d/%d)", page+1, pages),
+		Type:        discordgo.EmbedTypeRich,
+		Description: strings.Join(lines, "\n"),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: strings.ReplaceAll(archivingSettingsSummary(archivingInfo), "\n", " · "),
+		},
+	}
+
+	return []*discordgo.MessageEmbed{&embed}, []discordgo.MessageComponent{h.PageButtons("archivereport", page, pages)}, nil
+}
+
+func archiveReportCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	// counting the members of every channel takes a while
+	err = h.SendInteractionAwaitResponse(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	embeds, components, err := archiveReportPage(guildInfo, 0)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	err = h.EditInteractionResponseComplex(s, i, embeds, components)
+	if err != nil {
+		logger.Errorf("unable to send archive report to guild: %s", err)
+	}
+}
+
+func archiveReportPageButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionEphemeralResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	_, value := h.ParseComponentID(i.MessageComponentData().CustomID)
+
+	page, err := strconv.Atoi(value)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, "invalid page")
+		return
+	}
+
+	err = h.SendInteractionAwaitUpdate(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	embeds, components, err := archiveReportPage(guildInfo, page)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	err = h.EditInteractionResponseComplex(s, i, embeds, components)
+	if err != nil {
+		logger.Errorf("unable to send archive report to guild: %s", err)
+	}
+}
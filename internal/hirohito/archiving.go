@@ -0,0 +1,843 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// lastActivity derives the time of the last message in a channel from its snowflake.
+// Channels without messages fall back on their creation time.
+func lastActivity(channel *discordgo.Channel) (time.Time, error) {
+	id := channel.LastMessageID
+	if id == "" {
+		id = channel.ID
+	}
+
+	return discordgo.SnowflakeTimestamp(id)
+}
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
+
+// isInactive reports whether the last activity is at least interval days before now.
+// An interval of 0 or less disables archiving.
+func isInactive(now, lastActivity time.Time, interval int) bool {
+	if interval <= 0 {
+		return false
+	}
+
+	return now.Sub(lastActivity) >= days(interval)
+}
+
+// channelArchivingInfo applies the override of a channel to the archiving settings of its guild.
+// It reports false when the channel is exempt from archiving.
+func channelArchivingInfo(archivingInfo *m.ArchivingInformation, override *m.ArchiveOverride) (*m.ArchivingInformation, bool) {
+	if override == nil {
+		return archivingInfo, true
+	}
+
+	if override.Exempt == 1 {
+		return nil, false
+	}
+
+	channelInfo := *archivingInfo
+
+	if override.Interval > 0 {
+		channelInfo.Interval = override.Interval
+
+		// a short custom interval leaves less room for the warning
+		if channelInfo.WarningDays >= channelInfo.Interval {
+			channelInfo.WarningDays = channelInfo.Interval - 1
+		}
+	}
+
+	return &channelInfo, true
+}
+
+type archiveAction int
+
+const (
+	archiveActionNone archiveAction = iota
+	archiveActionWarn
+	archiveActionArchive
+)
+
+// nextArchiveAction decides what the archiver does with a channel. When warnings are enabled a
+// channel is only archived once its warning has been up for at least WarningDays.
+func nextArchiveAction(now, activity time.Time, archivingInfo *m.ArchivingInformation, warning *m.ArchiveWarning) archiveAction {
+	if archivingInfo.Interval <= 0 {
+		return archiveActionNone
+	}
+
+	if archivingInfo.WarningDays <= 0 {
+		if isInactive(now, activity, archivingInfo.Interval) {
+			return archiveActionArchive
+		}
+		return archiveActionNone
+	}
+
+	if warning == nil {
+		if now.Sub(activity) >= days(archivingInfo.Interval-archivingInfo.WarningDays) {
+			return archiveActionWarn
+		}
+		return archiveActionNone
+	}
+
+	if isInactive(now, activity, archivingInfo.Interval) && now.Sub(warning.WarnedAt) >= days(archivingInfo.WarningDays) {
+		return archiveActionArchive
+	}
+
+	return archiveActionNone
+}
+
+// pendingWarning returns the archive warning of a channel and whether it still holds. The warning
+// message itself does not count as activity, anything posted after it voids the warning.
+func pendingWarning(channel *discordgo.Channel, activity time.Time) (*m.ArchiveWarning, bool, error) {
+	warning, err := c.DataStore.GetArchiveWarning(channel.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	valid := channel.LastMessageID == warning.MessageID || !activity.After(warning.LastActivity)
+
+	return warning, valid, nil
+}
+
+// channelActivity returns the last activity of a channel and its pending archive warning.
+// Warnings voided by new activity are cleared.
+func channelActivity(channel *discordgo.Channel) (time.Time, *m.ArchiveWarning, error) {
+	activity, err := lastActivity(channel)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	warning, valid, err := pendingWarning(channel, activity)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	if warning == nil {
+		return activity, nil, nil
+	}
+
+	if valid {
+		return warning.LastActivity, warning, nil
+	}
+
+	clearArchiveWarning(warning)
+
+	return activity, nil, nil
+}
+
+func warnChannel(now, activity time.Time, archivingInfo *m.ArchivingInformation, channel *discordgo.Channel) error {
+	message, err := c.Messages.ArchiveWarningMessage(channel.ID, archivingInfo.WarningDays)
+	if err != nil {
+		return err
+	}
+
+	return c.DataStore.CreateArchiveWarning(m.ArchiveWarning{
+		GuildID:      channel.GuildID,
+		ChannelID:    channel.ID,
+		MessageID:    message.ID,
+		LastActivity: activity,
+		WarnedAt:     now,
+	})
+}
+
+func clearArchiveWarning(warning *m.ArchiveWarning) {
+	err := c.DataStore.DeleteArchiveWarning(warning.ChannelID)
+	if err != nil {
+		logger.Errorf("unable to delete archive warning of channel %s: %s", warning.ChannelID, err)
+		return
+	}
+
+	err = c.Messages.DeleteMessage(warning.ChannelID, warning.MessageID)
+	if err != nil {
+		logger.Warnf("unable to delete archive warning message in channel %s: %s", warning.ChannelID, err)
+	}
+}
+
+func archiveJoinableChannel(now time.Time, guildInfo *m.GuildInformation, archivingInfo *m.ArchivingInformation, channel *discordgo.Channel) error {
+	if archivingInfo.ArchivingCategoryID == "" {
+		return errors.New("no archiving category configured for this guild")
+	}
+
+	joinableChannel, err := joinableChannelRecord(channel)
+	if err != nil {
+		return err
+	}
+
+	// the history stays readable in the archive, so a failed export does not block archiving
+	err = exportTranscript(guildInfo, channel, "archived")
+	if err != nil {
+		logger.Warnf("unable to export transcript of channel %s: %s", channel.Name, err)
+	}
+
+	_, err = c.Channels.MoveChannel(channel, archivingInfo.ArchivingCategoryID, archivedPermissions(guildInfo, joinableChannel.RoleID, channel.Type))
+	if err != nil {
+		return err
+	}
+
+	defer refreshChannelDirectory(guildInfo)
+
+	err = c.DataStore.DeleteArchiveWarning(channel.ID)
+	if err != nil {
+		logger.Errorf("unable to delete archive warning of channel %s: %s", channel.Name, err)
+	}
+
+	err = c.DataStore.CreateArchivedChannel(m.ArchivedChannel{
+		GuildID:    guildInfo.GuildID,
+		ChannelID:  channel.ID,
+		ArchivedAt: now,
+	})
+	if err != nil {
+		logger.Errorf("unable to record archived channel %s: %s", channel.Name, err)
+	}
+
+	// the channel can no longer be joined, so the embed has to go
+	if joinableChannel.EmbedMessageID == "" {
+		return nil
+	}
+
+	group, err := channelGroup(guildInfo, joinableChannel.GroupName)
+	if err == nil {
+		err = c.Messages.DeleteMessage(group.JoinChannelID, joinableChannel.EmbedMessageID)
+	}
+	if err != nil {
+		logger.Warnf("unable to delete join embed of archived channel %s: %s", channel.Name, err)
+	}
+
+	joinableChannel.EmbedMessageID = ""
+
+	return c.DataStore.CreateJoinableChannel(*joinableChannel)
+}
+
+func unarchiveJoinableChannel(guildInfo *m.GuildInformation, channel *discordgo.Channel) error {
+	joinableChannel, err := joinableChannelRecord(channel)
+	if err != nil {
+		return err
+	}
+
+	group, err := channelGroup(guildInfo, joinableChannel.GroupName)
+	if err != nil {
+		return err
+	}
+
+	categoryID, err := groupCategory(guildInfo, group)
+	if err != nil {
+		return err
+	}
+
+	restoredChannel, err := c.Channels.MoveChannel(channel, categoryID, joinablePermissions(guildInfo, joinableChannel.RoleID, channel.Type))
+	if err != nil {
+		return err
+	}
+
+	err = c.DataStore.DeleteArchivedChannel(channel.ID)
+	if err != nil {
+		logger.Errorf("unable to delete archived channel record of %s: %s", channel.Name, err)
+	}
+
+	// the archiver measures activity by the last message, without a new one the channel would be
+	// warned or archived again right away. Forums are never archived by the archiver.
+	if channel.Type != discordgo.ChannelTypeGuildForum {
+		err = c.Messages.SendMessage(channel.ID, "📤 This channel was restored from the archive.")
+		if err != nil {
+			logger.Warnf("unable to post restore message in channel %s: %s", channel.Name, err)
+		}
+	}
+
+	joinableChannel.EmbedMessageID, err = announceJoinableChannel(guildInfo, group, restoredChannel)
+	if err != nil {
+		return err
+	}
+
+	return c.DataStore.CreateJoinableChannel(*joinableChannel)
+}
+
+// archiveCommandChannel runs the shared checks of the archive commands and returns
+// the named channel together with the archiving information of the guild
+func archiveCommandChannel(s *discordgo.Session, i *discordgo.InteractionCreate) (*m.GuildInformation, *m.ArchivingInformation, *discordgo.Channel, bool) {
+	var name string
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return nil, nil, nil, false
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return nil, nil, nil, false
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "channelname":
+			name = option.StringValue()
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return nil, nil, nil, false
+		}
+	}
+
+	if name == "" {
+		h.SendInteractionResponse(s, i, "name is empty. name needs to be between 2 and 100 characters.")
+		return nil, nil, nil, false
+	}
+
+	archivingInfo, err := c.DataStore.GetArchivingInfo(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, fmt.Sprintf("unable to retrieve archiving settings for this guild: %s", err))
+		return nil, nil, nil, false
+	}
+
+	if archivingInfo.ArchivingCategoryID == "" {
+		h.SendInteractionResponse(s, i, "no archiving category configured for this guild")
+		return nil, nil, nil, false
+	}
+
+	channel, _, err := joinableChannelByName(s, i.GuildID, name)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return nil, nil, nil, false
+	}
+
+	return guildInfo, archivingInfo, channel, true
+}
+
+func archiveChannel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, archivingInfo, channel, ok := archiveCommandChannel(s, i)
+	if !ok {
+		return
+	}
+
+	if joinable, _ := isJoinableCategory(guildInfo, channel.ParentID); !joinable {
+		h.SendInteractionResponse(s, i, "Requested channel is not a joinable channel.")
+		return
+	}
+
+	// exporting the transcript easily takes longer than discord waits for a response
+	err := h.SendInteractionAwaitResponse(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	err = archiveJoinableChannel(time.Now(), guildInfo, archivingInfo, channel)
+	if err != nil {
+		h.EditInteractionResponse(s, i, fmt.Sprintf("unable to archive channel: %s", err))
+		return
+	}
+
+	h.EditInteractionResponse(s, i, fmt.Sprintf("Channel archived: %v", channel.Mention()))
+}
+
+func unarchiveChannel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, archivingInfo, channel, ok := archiveCommandChannel(s, i)
+	if !ok {
+		return
+	}
+
+	if channel.ParentID != archivingInfo.ArchivingCategoryID {
+		h.SendInteractionResponse(s, i, "Requested channel is not an archived channel.")
+		return
+	}
+
+	err := unarchiveJoinableChannel(guildInfo, channel)
+	if err != nil {
+		h.SendInteractionResponse(s, i, fmt.Sprintf("unable to unarchive channel: %s", err))
+		return
+	}
+
+	h.SendInteractionResponse(s, i, fmt.Sprintf("Channel restored: %v", channel.Mention()))
+}
+
+// archivingTask archives the inactive joinable channels of every guild with auto archiving enabled
+// and purges archived channels that have been kept longer than the retention of their guild
+func archivingTask(now time.Time) {
+	archivingInfos, err := c.DataStore.GetAllArchivingInfo()
+	if err != nil {
+		logger.Errorf("unable to retrieve archiving information: %s", err)
+		return
+	}
+
+	for i := range archivingInfos {
+		archivingInfo := &archivingInfos[i]
+
+		if archivingInfo.Auto != 1 && archivingInfo.Retention <= 0 {
+			continue
+		}
+
+		guildInfo, err := checkGuildSetup(archivingInfo.GuildID)
+		if err != nil {
+			logger.Warnf("skipping archiving for guild %s: %s", archivingInfo.GuildID, err)
+			continue
+		}
+
+		if archivingInfo.Auto == 1 {
+			archiveInactiveChannels(now, guildInfo, archivingInfo)
+		}
+
+		if archivingInfo.Retention > 0 {
+			purgeArchivedChannels(now, guildInfo, archivingInfo)
+		}
+	}
+}
+
+func archiveInactiveChannels(now time.Time, guildInfo *m.GuildInformation, archivingInfo *m.ArchivingInformation) {
+	channels, err := joinableChannels(guildInfo)
+	if err != nil {
+		logger.Errorf("unable to retrieve joinable channels of guild %s: %s", guildInfo.GuildID, err)
+		return
+	}
+
+	overrides, err := c.DataStore.GetArchiveOverrides(guildInfo.GuildID)
+	if err != nil {
+		logger.Errorf("unable to retrieve archive overrides of guild %s: %s", guildInfo.GuildID, err)
+		return
+	}
+
+	for _, channel := range channels {
+		// forums are left alone, their activity happens in posts and no warning can be posted in them
+		if channel.Type == discordgo.ChannelTypeGuildForum {
+			continue
+		}
+
+		var override *m.ArchiveOverride
+		if o, found := overrides[channel.ID]; found {
+			override = &o
+		}
+
+		channelInfo, archivable := channelArchivingInfo(archivingInfo, override)
+		if !archivable {
+			continue
+		}
+
+		activity, warning, err := channelActivity(channel)
+		if err != nil {
+			logger.Errorf("unable to determine last activity of channel %s: %s", channel.Name, err)
+			continue
+		}
+
+		switch nextArchiveAction(now, activity, channelInfo, warning) {
+		case archiveActionWarn:
+			err = warnChannel(now, activity, channelInfo, channel)
+			if err != nil {
+				logger.Errorf("unable to warn channel %s in guild %s: %s", channel.Name, guildInfo.GuildID, err)
+				continue
+			}
+
+			logger.Infof("posted archive warning in channel %s in guild %s", channel.Name, guildInfo.GuildID)
+		case archiveActionArchive:
+			err = archiveJoinableChannel(now, guildInfo, archivingInfo, channel)
+			if err != nil {
+				logger.Errorf("unable to archive channel %s in guild %s: %s", channel.Name, guildInfo.GuildID, err)
+				continue
+			}
+
+			logger.Infof("archived inactive channel %s in guild %s", channel.Name, guildInfo.GuildID)
+		}
+	}
+}
+
+// purgeArchivedChannels removes archived channels that have been kept longer than the retention period.
+// Joinable channels that were archived before their archiving time was recorded start their retention now.
+// Channels without a joinable or archived channel record are never purged.
+func purgeArchivedChannels(now time.Time, guildInfo *m.GuildInformation, archivingInfo *m.ArchivingInformation) {
+	if archivingInfo.ArchivingCategoryID == "" {
+		return
+	}
+
+	channels, err := c.Channels.GetChannelsInCategory(guildInfo.GuildID, archivingInfo.ArchivingCategoryID)
+	if err != nil {
+		logger.Errorf("unable to retrieve archived channels of guild %s: %s", guildInfo.GuildID, err)
+		return
+	}
+
+	archivedChannels, err := c.DataStore.GetArchivedChannels(guildInfo.GuildID)
+	if err != nil {
+		logger.Errorf("unable to retrieve archived channels of guild %s: %s", guildInfo.GuildID, err)
+		return
+	}
+
+	records, err := joinableChannelRecords(guildInfo.GuildID)
+	if err != nil {
+		logger.Errorf("unable to retrieve joinable channels of guild %s: %s", guildInfo.GuildID, err)
+		return
+	}
+
+	for _, channel := range channels {
+		archivedChannel, found := archivedChannels[channel.ID]
+		_, joinable := records[channel.ID]
+
+		// channels the bot does not know about were parked in the archive by hand and are left alone
+		if !found && !joinable {
+			continue
+		}
+
+		if !found {
+			err = c.DataStore.CreateArchivedChannel(m.ArchivedChannel{
+				GuildID:    guildInfo.GuildID,
+				ChannelID:  channel.ID,
+				ArchivedAt: now,
+			})
+			if err != nil {
+				logger.Errorf("unable to record archived channel %s: %s", channel.Name, err)
+			}
+			continue
+		}
+
+		if now.Sub(archivedChannel.ArchivedAt) < days(archivingInfo.Retention) {
+			continue
+		}
+
+		summary, err := purgeArchivedChannel(guildInfo, channel, archivedChannel)
+		if err != nil {
+			logger.Errorf("unable to purge archived channel %s in guild %s: %s", channel.Name, guildInfo.GuildID, err)
+			continue
+		}
+
+		logger.Infof("purged archived channel %s in guild %s", channel.Name, guildInfo.GuildID)
+
+		err = c.Messages.SendMessage(guildInfo.AdminChannelID, summary)
+		if err != nil {
+			logger.Errorf("unable to post purge summary to guild %s: %s", guildInfo.GuildID, err)
+		}
+	}
+}
+
+// purgeArchivedChannel exports a final transcript and removes the channel, its role and its datastore rows.
+// It returns a summary of the purge for the admin channel.
+func purgeArchivedChannel(guildInfo *m.GuildInformation, channel *discordgo.Channel, archivedChannel m.ArchivedChannel) (string, error) {
+	transcriptResult := "transcript exported"
+
+	// a channel that cannot be exported must still be purged eventually, so a failed export is only reported
+	err := exportTranscript(guildInfo, channel, "purged")
+	if err != nil {
+		logger.Errorf("unable to export final transcript of channel %s: %s", channel.Name, err)
+		transcriptResult = "transcript export failed"
+	}
+
+	roleResult := "no role found"
+
+	joinableChannel, err := c.DataStore.GetJoinableChannel(channel.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	if joinableChannel != nil {
+		// a role that was deleted by hand must not keep the channel from being purged
+		err = c.Roles.DeleteRole(guildInfo.GuildID, joinableChannel.RoleID)
+		switch {
+		case h.IsUnknownRole(err):
+		case err != nil:
+			return "", fmt.Errorf("unable to delete role: %s", err)
+		default:
+			roleResult = "role deleted"
+		}
+	}
+
+	err = c.Channels.DeleteTextChannel(channel.ID)
+	if err != nil {
+		return "", fmt.Errorf("unable to delete channel: %s", err)
+	}
+
+	deleteJoinableChannelRecords(channel.ID, channel.Name)
+
+	return fmt.Sprintf("🗑️ Purged archived channel #%s (archived %s): %s, %s, channel deleted.", channel.Name, archivedChannel.ArchivedAt.UTC().Format("2006-01-02"), transcriptResult, roleResult), nil
+}
+
+func keepChannel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	_, channelID := h.ParseComponentID(i.MessageComponentData().CustomID)
+
+	warning, err := c.DataStore.GetArchiveWarning(channelID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.SendInteractionEphemeralResponse(s, i, "This channel has no pending archive warning.")
+			return
+		}
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	err = c.DataStore.DeleteArchiveWarning(channelID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	// the response is posted in the channel and thereby resets the inactivity timer
+	err = h.SendInteractionResponse(s, i, fmt.Sprintf("%s wants to keep this channel. It will not be archived for now.", i.Member.User.Mention()))
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+	}
+
+	err = c.Messages.DeleteMessage(channelID, warning.MessageID)
+	if err != nil {
+		logger.Warnf("unable to delete archive warning message in channel %s: %s", channelID, err)
+	}
+}
+
+func archiveSettings(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	archivingInfo, err := c.DataStore.GetArchivingInfo(i.GuildID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			h.SendInteractionResponse(s, i, err.Error())
+			return
+		}
+
+		archivingInfo = &m.ArchivingInformation{
+			GuildID:     i.GuildID,
+			Interval:    defaultArchiveInterval,
+			WarningDays: defaultArchiveWarningDays,
+		}
+	}
+
+	if len(i.ApplicationCommandData().Options) < 1 {
+		h.SendInteractionResponse(s, i, archivingSettingsSummary(archivingInfo))
+		return
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "auto":
+			archivingInfo.Auto = 0
+			if option.BoolValue() {
+				archivingInfo.Auto = 1
+			}
+		case "interval":
+			archivingInfo.Interval = int(option.IntValue())
+		case "archivecategoryid":
+			archivingInfo.ArchivingCategoryID = option.StringValue()
+		case "warningdays":
+			archivingInfo.WarningDays = int(option.IntValue())
+		case "retention":
+			archivingInfo.Retention = int(option.IntValue())
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	if archivingInfo.Auto == 1 && archivingInfo.ArchivingCategoryID == "" {
+		h.SendInteractionResponse(s, i, "auto archiving requires an archive category. Provide one with the archivecategoryid option.")
+		return
+	}
+
+	if archivingInfo.WarningDays >= archivingInfo.Interval {
+		h.SendInteractionResponse(s, i, "the warning has to be posted less days ahead than the inactivity interval.")
+		return
+	}
+
+	err = c.DataStore.CreateArchivingInfo(*archivingInfo)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	h.SendInteractionResponse(s, i, fmt.Sprintf("Archiving settings saved.\n%s", archivingSettingsSummary(archivingInfo)))
+}
+
+func archivingSettingsSummary(archivingInfo *m.ArchivingInformation) string {
+	category := "not set"
+	if archivingInfo.ArchivingCategoryID != "" {
+		category = fmt.Sprintf("<#%s>", archivingInfo.ArchivingCategoryID)
+	}
+
+	warning := "disabled"
+	if archivingInfo.WarningDays > 0 {
+		warning = fmt.Sprintf("%d days ahead", archivingInfo.WarningDays)
+	}
+
+	retention := "forever"
+	if archivingInfo.Retention > 0 {
+		retention = fmt.Sprintf("%d days", archivingInfo.Retention)
+	}
+
+	return fmt.Sprintf("auto archiving: %t\ninactivity interval: %d days\narchive category: %s\narchive warning: %s\narchived channels kept: %s", archivingInfo.Auto == 1, archivingInfo.Interval, category, warning, retention)
+}
+
+// archiveOverrideChannel runs the shared checks of the archive override commands. It returns the
+// named joinable channel and its current override, or a fresh one when the channel has none.
+func archiveOverrideChannel(s *discordgo.Session, i *discordgo.InteractionCreate, name string) (*discordgo.Channel, *m.ArchiveOverride, error) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !h.PermissionChecker(guildInfo, i) {
+		return nil, nil, errors.New(h.InsufficientPermissions)
+	}
+
+	if name == "" {
+		return nil, nil, errors.New("name is empty. name needs to be between 2 and 100 characters.")
+	}
+
+	channel, _, err := joinableChannelByName(s, i.GuildID, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	joinable, err := isJoinableCategory(guildInfo, channel.ParentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !joinable {
+		return nil, nil, errors.New("Requested channel is not a joinable channel.")
+	}
+
+	override, err := c.DataStore.GetArchiveOverride(channel.ID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, err
+		}
+
+		override = &m.ArchiveOverride{
+			GuildID:   i.GuildID,
+			ChannelID: channel.ID,
+		}
+	}
+
+	return channel, override, nil
+}
+
+// saveArchiveOverride stores an override, or removes it once it no longer differs from the guild settings
+func saveArchiveOverride(override *m.ArchiveOverride) error {
+	if override.Exempt == 0 && override.Interval == 0 {
+		return c.DataStore.DeleteArchiveOverride(override.ChannelID)
+	}
+
+	return c.DataStore.CreateArchiveOverride(*override)
+}
+
+func archiveExempt(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name string
+	var exempt bool
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "channelname":
+			name = option.StringValue()
+		case "exempt":
+			exempt = option.BoolValue()
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	channel, override, err := archiveOverrideChannel(s, i, name)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	override.Exempt = 0
+	if exempt {
+		override.Exempt = 1
+	}
+
+	err = saveArchiveOverride(override)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	if !exempt {
+		h.SendInteractionResponse(s, i, fmt.Sprintf("Channel %v can be archived again.", channel.Mention()))
+		return
+	}
+
+	// an exempt channel will never be archived, so a pending warning is void
+	warning, err := c.DataStore.GetArchiveWarning(channel.ID)
+	if err == nil {
+		clearArchiveWarning(warning)
+	}
+
+	h.SendInteractionResponse(s, i, fmt.Sprintf("Channel %v is exempt from archiving.", channel.Mention()))
+}
+
+func archiveInterval(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name string
+	var interval int
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "channelname":
+			name = option.StringValue()
+		case "interval":
+			interval = int(option.IntValue())
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	channel, override, err := archiveOverrideChannel(s, i, name)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	override.Interval = interval
+
+	err = saveArchiveOverride(override)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	if interval == 0 {
+		h.SendInteractionResponse(s, i, fmt.Sprintf("Channel %v uses the guild inactivity interval again.", channel.Mention()))
+		return
+	}
+
+	h.SendInteractionResponse(s, i, fmt.Sprintf("Channel %v is archived after %d days of inactivity.", channel.Mention(), interval))
+}
@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	m "hirohito/internal/models"
+	"testing"
+	"time"
+)
+
+func TestNextArchiveAction(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(n int) time.Time { return now.Add(-days(n)) }
+
+	tests := []struct {
+		name          string
+		activity      time.Time
+		archivingInfo m.ArchivingInformation
+		warning       *m.ArchiveWarning
+		want          archiveAction
+	}{
+		{
+			name:          "archiving disabled",
+			activity:      ago(365),
+			archivingInfo: m.ArchivingInformation{Interval: 0, WarningDays: 7},
+			want:          archiveActionNone,
+		},
+		{
+			name:          "active channel without warnings",
+			activity:      ago(10),
+			archivingInfo: m.ArchivingInformation{Interval: 30},
+			want:          archiveActionNone,
+		},
+		{
+			name:          "inactive channel without warnings",
+			activity:      ago(30),
+			archivingInfo: m.ArchivingInformation{Interval: 30},
+			want:          archiveActionArchive,
+		},
+		{
+			name:          "not yet inside the warning period",
+			activity:      ago(22),
+			archivingInfo: m.ArchivingInformation{Interval: 30, WarningDays: 7},
+			want:          archiveActionNone,
+		},
+		{
+			name:          "warning period started",
+			activity:      ago(23),
+			archivingInfo: m.ArchivingInformation{Interval: 30, WarningDays: 7},
+			want:          archiveActionWarn,
+		},
+		{
+			name:          "inactive but not warned",
+			activity:      ago(60),
+			archivingInfo: m.ArchivingInformation{Interval: 30, WarningDays: 7},
+			want:          archiveActionWarn,
+		},
+		{
+			name:          "warned but warning not up long enough",
+			activity:      ago(30),
+			archivingInfo: m.ArchivingInformation{Interval: 30, WarningDays: 7},
+			warning:       &m.ArchiveWarning{WarnedAt: ago(3)},
+			want:          archiveActionNone,
+		},
+		{
+			name:          "warned and warning period passed",
+			activity:      ago(30),
+			archivingInfo: m.ArchivingInformation{Interval: 30, WarningDays: 7},
+			warning:       &m.ArchiveWarning{WarnedAt: ago(7)},
+			want:          archiveActionArchive,
+		},
+		{
+			name:          "warned but the interval got longer",
+			activity:      ago(30),
+			archivingInfo: m.ArchivingInformation{Interval: 60, WarningDays: 7},
+			warning:       &m.ArchiveWarning{WarnedAt: ago(7)},
+			want:          archiveActionNone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextArchiveAction(now, tt.activity, &tt.archivingInfo, tt.warning)
+			if got != tt.want {
+				t.Errorf("nextArchiveAction() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
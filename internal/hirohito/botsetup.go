@@ -112,4 +112,10 @@ func setupGuild(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
 	h.SendInteractionResponse(s, i, "Guild setup completed")
 
+	// guilds might already have joinable channels from before the setup
+	err = backfillJoinableChannels(&guildInfo)
+	if err != nil {
+		logger.Errorf("unable to backfill joinable channels of guild %s: %s", guildInfo.GuildID, err)
+	}
+
 }
@@ -0,0 +1,137 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const channelListPageSize = 10
+
+func channelListPage(guildInfo *m.GuildInformation, page int) ([]*discordgo.MessageEmbed, []discordgo.MessageComponent, error) {
+	channels, err := joinableChannels(guildInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, err := joinableChannelRecords(guildInfo.GuildID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	memberCounts, err := c.Users.CountRoleMembers(guildInfo.GuildID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to count channel members: %s", err)
+	}
+
+	start, end, page, pages := h.Paginate(len(channels), channelListPageSize, page)
+
+	embed := discordgo.MessageEmbed{
+		Title: fmt.Sprintf("Joinable channels (page %d/%d)", page+1, pages),
+		Type:  discordgo.EmbedTypeRich,
+	}
+
+	if len(channels) == 0 {
+		embed.Description = "There are no joinable channels."
+	}
+
+	for _, channel := range channels[start:end] {
+		joinableChannel := records[channel.ID]
+
+		topic := h.Truncate(channel.Topic, 200)
+		if topic == "" {
+			topic = "no topic"
+		}
+
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "#" + channel.Name,
+			Value: fmt.Sprintf("%s\n%s · %d members · created <t:%d:D>", topic, channel.Mention(), memberCounts[joinableChannel.RoleID], joinableChannel.CreatedAt.Unix()),
+		})
+	}
+
+	return []*discordgo.MessageEmbed{&embed}, []discordgo.MessageComponent{h.PageButtons("listjoinable", page, pages)}, nil
+}
+
+func listJoinableChannels(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	// counting the members of every channel takes a while
+	err = h.SendInteractionAwaitEphemeralResponse(s, i)
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	embeds, components, err := channelListPage(guildInfo, 0)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	err = h.EditInteractionResponseComplex(s, i, embeds, components)
+	if err != nil {
+		logger.Errorf("unable to send channel list to guild: %s", err)
+	}
+}
+
+func listJoinableChannelsPageButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	_, value := h.ParseComponentID(i.MessageComponentData().CustomID)
+
+	page, err := strconv.Atoi(value)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, "invalid page")
+		return
+	}
+
+	err = h.SendInteractionAwaitUpdate(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	embeds, components, err := channelListPage(guildInfo, page)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	err = h.EditInteractionResponseComplex(s, i, embeds, components)
+	if err != nil {
+		logger.Errorf("unable to send channel list to guild: %s", err)
+	}
+}
@@ -22,17 +22,246 @@ CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 package hirohito
 
 import (
+	"errors"
 	"fmt"
 	c "hirohito/internal/config"
 	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+// channelTypePermissions holds the permissions that differ between the types of joinable channels
+type channelTypePermissions struct {
+	member int64 // granted to the channel role on top of read access
+	staff  int64 // granted to admins and moderators on top of read access
+	write  int64 // denied to everyone while the channel is archived
+}
+
+const readPermissions = discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory
+
+// joinableChannelTypes are the types of channels that can be made joinable
+var joinableChannelTypes = map[discordgo.ChannelType]channelTypePermissions{
+	discordgo.ChannelTypeGuildText: {
+		member: discordgo.PermissionMentionEveryone,
+		write:  discordgo.PermissionSendMessages,
+	},
+	discordgo.ChannelTypeGuildVoice: {
+		member: discordgo.PermissionVoiceConnect | discordgo.PermissionVoiceSpeak,
+		staff:  discordgo.PermissionVoiceConnect | discordgo.PermissionVoiceSpeak,
+		write:  discordgo.PermissionSendMessages | discordgo.PermissionVoiceConnect,
+	},
+	discordgo.ChannelTypeGuildStageVoice: {
+		member: discordgo.PermissionVoiceConnect | discordgo.PermissionVoiceRequestToSpeak,
+		staff:  discordgo.PermissionVoiceConnect | discordgo.PermissionVoiceMuteMembers | discordgo.PermissionVoiceMoveMembers,
+		write:  discordgo.PermissionSendMessages | discordgo.PermissionVoiceConnect,
+	},
+	discordgo.ChannelTypeGuildForum: {
+		member: discordgo.PermissionSendMessagesInThreads | discordgo.PermissionCreatePublicThreads,
+		staff:  discordgo.PermissionSendMessagesInThreads | discordgo.PermissionManageThreads,
+		write:  discordgo.PermissionSendMessages | discordgo.PermissionSendMessagesInThreads | discordgo.PermissionCreatePublicThreads,
+	},
+}
+
+// channelTypeOptions maps the choices of the channel type option to the type of channel
+var channelTypeOptions = map[string]discordgo.ChannelType{
+	"text":  discordgo.ChannelTypeGuildText,
+	"voice": discordgo.ChannelTypeGuildVoice,
+	"stage": discordgo.ChannelTypeGuildStageVoice,
+	"forum": discordgo.ChannelTypeGuildForum,
+}
+
+func permissionsOfType(channelType discordgo.ChannelType) channelTypePermissions {
+	permissions, found := joinableChannelTypes[channelType]
+	if !found {
+		return joinableChannelTypes[discordgo.ChannelTypeGuildText]
+	}
+
+	return permissions
+}
+
+// joinablePermissions returns the permission overwrites of an active joinable channel.
+// Only members of the channel role, admins and moderators can see the channel.
+func joinablePermissions(guildInfo *m.GuildInformation, roleID string, channelType discordgo.ChannelType) []*discordgo.PermissionOverwrite {
+	permissions := permissionsOfType(channelType)
+
+	return []*discordgo.PermissionOverwrite{
+		{
+			ID:    roleID,
+			Type:  discordgo.PermissionOverwriteTypeRole,
+			Allow: readPermissions | permissions.member,
+		},
+		{
+			ID:   guildInfo.AnyoneRoleID,
+			Type: discordgo.PermissionOverwriteTypeRole,
+			Deny: discordgo.PermissionViewChannel,
+		},
+		{
+			ID:    guildInfo.AdminRoleID,
+			Type:  discordgo.PermissionOverwriteTypeRole,
+			Allow: readPermissions | permissions.staff,
+		},
+		{
+			ID:    guildInfo.ModeratorRoleID,
+			Type:  discordgo.PermissionOverwriteTypeRole,
+			Allow: readPermissions | permissions.staff,
+		},
+	}
+}
+
+// archivedPermissions returns the permission overwrites of an archived joinable channel.
+// Members of the channel role keep read access to the history, but nobody can post anymore.
+func archivedPermissions(guildInfo *m.GuildInformation, roleID string, channelType discordgo.ChannelType) []*discordgo.PermissionOverwrite {
+	permissions := permissionsOfType(channelType)
+
+	return []*discordgo.PermissionOverwrite{
+		{
+			ID:    roleID,
+			Type:  discordgo.PermissionOverwriteTypeRole,
+			Allow: readPermissions,
+			Deny:  permissions.write,
+		},
+		{
+			ID:   guildInfo.AnyoneRoleID,
+			Type: discordgo.PermissionOverwriteTypeRole,
+			Deny: discordgo.PermissionViewChannel | permissions.write,
+		},
+		{
+			ID:    guildInfo.AdminRoleID,
+			Type:  discordgo.PermissionOverwriteTypeRole,
+			Allow: readPermissions,
+			Deny:  permissions.write,
+		},
+		{
+			ID:    guildInfo.ModeratorRoleID,
+			Type:  discordgo.PermissionOverwriteTypeRole,
+			Allow: readPermissions,
+			Deny:  permissions.write,
+		},
+	}
+}
+
+// createChannel creates a channel of the given type through the matching function of the channels package
+func createChannel(guildID string, channelType discordgo.ChannelType, channelData discordgo.GuildChannelCreateData) (*discordgo.Channel, error) {
+	switch channelType {
+	case discordgo.ChannelTypeGuildVoice:
+		return c.Channels.CreateVoiceChannel(guildID, channelData)
+	case discordgo.ChannelTypeGuildStageVoice:
+		return c.Channels.CreateStageChannel(guildID, channelData)
+	case discordgo.ChannelTypeGuildForum:
+		return c.Channels.CreateForumChannel(guildID, channelData)
+	default:
+		return c.Channels.CreateTextChannel(guildID, channelData)
+	}
+}
+
+// channelName normalizes a requested channel name the way discord would for text channels
+func channelName(name string) string {
+	name = strings.ToLower(name)
+	return strings.ReplaceAll(name, " ", "-")
+}
+
+// createChannelRole creates the role that gives access to a joinable channel
+func createChannelRole(guildID, name string) (*discordgo.Role, error) {
+	roleData := discordgo.RoleParams{
+		Name:        name,
+		Hoist:       &falseBool,
+		Mentionable: &falseBool,
+	}
+
+	return c.Roles.CreateRole(guildID, &roleData)
+}
+
+// joinableChannelSpec describes a joinable channel to be created
+type joinableChannelSpec struct {
+	name             string
+	topic            string
+	channelType      discordgo.ChannelType
+	groupName        string
+	creatorID        string
+	expiresAt        time.Time // zero for channels that do not expire
+	expiryArchive    int
+	requiresApproval int
+	capacity         int
+}
+
+// newJoinableChannel creates the role and the channel of a joinable channel in the category of its group,
+// records it and announces it in the join channel of the group
+func newJoinableChannel(guildInfo *m.GuildInformation, spec joinableChannelSpec) (*discordgo.Channel, *m.JoinableChannel, error) {
+	guildChannels, err := discordClient.GuildChannels(guildInfo.GuildID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve list of guild channels to check uniqueness: %s", err)
+	}
+
+	if _, found := h.FindChannel(guildChannels, spec.name); found {
+		return nil, nil, errors.New("requested channel name already exists")
+	}
+
+	group, err := channelGroup(guildInfo, spec.groupName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	categoryID, err := groupCategory(guildInfo, group)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	role, err := createChannelRole(guildInfo.GuildID, spec.name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	channelData := discordgo.GuildChannelCreateData{
+		Name:                 spec.name,
+		Topic:                spec.topic,
+		ParentID:             categoryID,
+		PermissionOverwrites: joinablePermissions(guildInfo, role.ID, spec.channelType),
+	}
+
+	channel, err := createChannel(guildInfo.GuildID, spec.channelType, channelData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	joinableChannel := m.JoinableChannel{
+		GuildID:          guildInfo.GuildID,
+		ChannelID:        channel.ID,
+		RoleID:           role.ID,
+		CreatorID:        spec.creatorID,
+		CreatedAt:        time.Now(),
+		GroupName:        group.Name,
+		ExpiresAt:        spec.expiresAt,
+		ExpiryArchive:    spec.expiryArchive,
+		RequiresApproval: spec.requiresApproval,
+		Capacity:         spec.capacity,
+	}
+
+	// the channel is recorded before it is announced, the channel directory only lists recorded channels
+	err = c.DataStore.CreateJoinableChannel(joinableChannel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("channel created, but it could not be recorded: %s", err)
+	}
+
+	joinableChannel.EmbedMessageID, err = announceJoinableChannel(guildInfo, group, channel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = c.DataStore.CreateJoinableChannel(joinableChannel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("channel created, but its join embed could not be recorded: %s", err)
+	}
+
+	return channel, &joinableChannel, nil
+}
+
 func createJoinableChannel(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	var permission []*discordgo.PermissionOverwrite
-	var name, topic string
+	var name, topic, groupName, expires string
+	var expiryArchive int
+	channelType := discordgo.ChannelTypeGuildText
 
 	guildInfo, err := checkGuildSetup(i.GuildID)
 	if err != nil {
@@ -49,10 +278,19 @@ func createJoinableChannel(s *discordgo.Session, i *discordgo.InteractionCreate)
 	for _, option := range i.ApplicationCommandData().Options {
 		switch option.Name {
 		case "channelname":
-			name = strings.ToLower(option.StringValue())
-			name = strings.ReplaceAll(name, " ", "-")
+			name = channelName(option.StringValue())
 		case "topic":
 			topic = option.StringValue()
+		case "type":
+			channelType = channelTypeOptions[option.StringValue()]
+		case "group":
+			groupName = strings.ToLower(option.StringValue())
+		case "expires":
+			expires = option.StringValue()
+		case "onexpiry":
+			if option.StringValue() == "archive" {
+				expiryArchive = 1
+			}
 		default:
 			h.SendInteractionResponse(s, i, h.UnknownOption)
 			return
@@ -64,72 +302,117 @@ func createJoinableChannel(s *discordgo.Session, i *discordgo.InteractionCreate)
 		return
 	}
 
-	guildChannels, err := s.GuildChannels(i.GuildID)
+	var expiresAt time.Time
+	if expires != "" {
+		expiresAt, err = parseExpiry(time.Now(), expires)
+		if err != nil {
+			h.SendInteractionResponse(s, i, err.Error())
+			return
+		}
+	}
+
+	channel, _, err := newJoinableChannel(guildInfo, joinableChannelSpec{
+		name:          name,
+		topic:         topic,
+		channelType:   channelType,
+		groupName:     groupName,
+		creatorID:     i.Member.User.ID,
+		expiresAt:     expiresAt,
+		expiryArchive: expiryArchive,
+	})
 	if err != nil {
-		h.SendInteractionResponse(s, i, fmt.Sprintf("Unable to retrieve list of guild channels to check uniqueness: %s", err))
+		h.SendInteractionResponse(s, i, err.Error())
 		return
 	}
 
-	if _, found := h.FindChannel(guildChannels, name); found {
-		h.SendInteractionResponse(s, i, "Requested channel name already exists.")
+	if !expiresAt.IsZero() {
+		h.SendInteractionResponse(s, i, fmt.Sprintf("Channel created: %v, it expires <t:%d:f>", channel.Mention(), expiresAt.Unix()))
 		return
 	}
 
-	roleData := discordgo.RoleParams{
-		Name:        name,
-		Hoist:       &falseBool,
-		Mentionable: &falseBool,
-	}
+	h.SendInteractionResponse(s, i, fmt.Sprintf("Channel created: %v", channel.Mention()))
+}
 
-	role, err := c.Roles.CreateRole(i.GuildID, &roleData)
+func editJoinableChannel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name, newName, topic string
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
 	if err != nil {
 		h.SendInteractionResponse(s, i, err.Error())
 		return
 	}
 
-	permission = append(permission,
-		&discordgo.PermissionOverwrite{
-			ID:    role.ID,
-			Type:  discordgo.PermissionOverwriteTypeRole,
-			Allow: 197632,
-		},
-		&discordgo.PermissionOverwrite{
-			ID:   guildInfo.AnyoneRoleID,
-			Type: discordgo.PermissionOverwriteTypeRole,
-			Deny: 1024,
-		},
-		&discordgo.PermissionOverwrite{
-			ID:    guildInfo.AdminRoleID,
-			Type:  discordgo.PermissionOverwriteTypeRole,
-			Allow: 66560,
-		},
-		&discordgo.PermissionOverwrite{
-			ID:    guildInfo.ModeratorRoleID,
-			Type:  discordgo.PermissionOverwriteTypeRole,
-			Allow: 66560,
-		},
-	)
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
 
-	channelData := discordgo.GuildChannelCreateData{
-		Name:                 name,
-		Topic:                topic,
-		ParentID:             guildInfo.JoinableChannelsCategoryID,
-		PermissionOverwrites: permission,
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "channelname":
+			name = option.StringValue()
+		case "newname":
+			newName = channelName(option.StringValue())
+		case "topic":
+			topic = option.StringValue()
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	if newName == "" && topic == "" {
+		h.SendInteractionResponse(s, i, "nothing to change. Provide a new name, a new topic or both.")
+		return
 	}
 
-	channel, err := c.Channels.CreateTextChannel(i.GuildID, channelData)
+	guildChannel, joinableChannel, err := joinableChannelByName(s, i.GuildID, name)
 	if err != nil {
 		h.SendInteractionResponse(s, i, err.Error())
 		return
 	}
 
-	err = c.Messages.JoinableChannelEmbed(i.GuildID, guildInfo.JoinChannelID, channel)
+	if newName == guildChannel.Name {
+		newName = ""
+	}
+
+	if newName != "" {
+		guildChannels, err := s.GuildChannels(i.GuildID)
+		if err != nil {
+			h.SendInteractionResponse(s, i, fmt.Sprintf("Unable to retrieve list of guild channels to check uniqueness: %s", err))
+			return
+		}
+
+		if _, found := h.FindChannel(guildChannels, newName); found {
+			h.SendInteractionResponse(s, i, "Requested channel name already exists.")
+			return
+		}
+	}
+
+	editedChannel, err := c.Channels.EditChannel(guildChannel, newName, topic)
 	if err != nil {
 		h.SendInteractionResponse(s, i, err.Error())
 		return
 	}
 
-	h.SendInteractionResponse(s, i, fmt.Sprintf("Channel created: %v", channel.Mention()))
+	if newName != "" {
+		err = c.Roles.RenameRole(i.GuildID, joinableChannel.RoleID, newName)
+		if err != nil {
+			h.SendInteractionResponse(s, i, fmt.Sprintf("channel edited, but its role could not be renamed: %s", err))
+			return
+		}
+	}
+
+	err = refreshJoinEmbed(guildInfo, editedChannel, joinableChannel)
+	if err != nil {
+		h.SendInteractionResponse(s, i, fmt.Sprintf("channel edited, but its join embed could not be updated: %s", err))
+		return
+	}
+
+	refreshChannelDirectory(guildInfo)
+
+	h.SendInteractionResponse(s, i, fmt.Sprintf("Channel edited: %v", editedChannel.Mention()))
 }
 
 func deleteJoinableChannel(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -162,51 +445,68 @@ func deleteJoinableChannel(s *discordgo.Session, i *discordgo.InteractionCreate)
 		return
 	}
 
-	messages, err := c.Messages.GetMessagesInChannel(guildInfo.JoinChannelID)
+	guildChannel, joinableChannel, err := joinableChannelByName(s, i.GuildID, name)
 	if err != nil {
 		h.SendInteractionResponse(s, i, err.Error())
 		return
 	}
 
-	message, err := h.FindChannelEmbedMessage(messages, name)
+	// exporting the transcript easily takes longer than discord waits for a response
+	err = h.SendInteractionAwaitResponse(s, i, "")
 	if err != nil {
-		h.SendInteractionResponse(s, i, err.Error())
+		logger.Errorf("unable to send response to guild: %s", err)
 		return
 	}
 
-	err = c.Messages.DeleteMessage(guildInfo.JoinChannelID, message.ID)
+	transcriptResult, err := removeJoinableChannel(guildInfo, guildChannel, joinableChannel, "deleted")
 	if err != nil {
-		h.SendInteractionResponse(s, i, err.Error())
+		h.EditInteractionResponse(s, i, err.Error())
+		return
 	}
 
-	roles, err := c.Roles.RetrieveRoles(guildInfo.GuildID)
+	err = h.EditInteractionResponse(s, i, fmt.Sprintf("Channel deleted, %s.", transcriptResult))
 	if err != nil {
-		h.SendInteractionResponse(s, i, err.Error())
-		return
+		logger.Errorf("unable to send response to guild: %s", err)
+	}
+
+}
+
+// removeJoinableChannel exports a transcript of a joinable channel and removes its join embed, its role,
+// the channel itself and its datastore rows. The reason ends up in the name of the transcript.
+// A failed export does not stop the removal, the outcome of the export is returned for the admins instead.
+func removeJoinableChannel(guildInfo *m.GuildInformation, channel *discordgo.Channel, joinableChannel *m.JoinableChannel, reason string) (string, error) {
+	transcriptResult := "transcript exported"
+
+	// a channel that cannot be exported must still be removable, so a failed export is only reported
+	err := exportTranscript(guildInfo, channel, reason)
+	if err != nil {
+		logger.Errorf("unable to export transcript of channel %s: %s", channel.Name, err)
+		transcriptResult = fmt.Sprintf("transcript export failed (%s)", err)
 	}
 
-	pos, found := h.FindChannelRole(roles, name)
-	if found {
-		err = c.Roles.DeleteRole(guildInfo.GuildID, roles[pos].ID)
+	if joinableChannel.EmbedMessageID != "" {
+		group, err := channelGroup(guildInfo, joinableChannel.GroupName)
+		if err == nil {
+			err = c.Messages.DeleteMessage(group.JoinChannelID, joinableChannel.EmbedMessageID)
+		}
 		if err != nil {
-			h.SendInteractionResponse(s, i, err.Error())
+			logger.Warnf("unable to delete join embed of channel %s: %s", channel.Name, err)
 		}
 	}
 
-	guildChannel, err := h.FindChannelInGuild(s, i.GuildID, name)
+	err = c.Roles.DeleteRole(guildInfo.GuildID, joinableChannel.RoleID)
 	if err != nil {
-		h.SendInteractionResponse(s, i, err.Error())
+		logger.Warnf("unable to delete role of channel %s: %s", channel.Name, err)
 	}
 
-	err = c.Channels.DeleteTextChannel(guildChannel.ID)
+	err = c.Channels.DeleteTextChannel(channel.ID)
 	if err != nil {
-		h.SendInteractionResponse(s, i, fmt.Sprintf("unable to delete channel: %s", err))
-		return
+		return "", fmt.Errorf("unable to delete channel (%s): %s", transcriptResult, err)
 	}
 
-	err = h.SendInteractionResponse(s, i, "Channel deleted")
-	if err != nil {
-		logger.Errorf("unable to send response to guild: %s", err)
-	}
+	deleteJoinableChannelRecords(channel.ID, channel.Name)
+
+	refreshChannelDirectory(guildInfo)
 
+	return transcriptResult, nil
 }
@@ -0,0 +1,541 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// consistencyIssue is a difference between the joinable channel records and the guild, together with its repair
+type consistencyIssue struct {
+	description string
+	repair      func() error
+}
+
+// checkConsistency compares the joinable channel records with the channels in the categories of the channel
+// groups, the guild roles and the join embeds in the join channels of the groups
+func checkConsistency(guildInfo *m.GuildInformation) ([]consistencyIssue, error) {
+	var issues []consistencyIssue
+
+	records, err := c.DataStore.GetJoinableChannels(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	guildChannels, err := discordClient.GuildChannels(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := c.Roles.RetrieveRoles(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	directory, err := channelDirectory(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := channelGroups(guildInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := joinableCategories(guildInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	// groups may share a join channel, which is read only once
+	var messages []*discordgo.Message
+	groupsByName := make(map[string]*m.ChannelGroup, len(groups))
+	joinChannels := make(map[string]bool, len(groups))
+	for i, group := range groups {
+		groupsByName[group.Name] = &groups[i]
+
+		if joinChannels[group.JoinChannelID] {
+			continue
+		}
+		joinChannels[group.JoinChannelID] = true
+
+		groupMessages, err := c.Messages.GetMessagesInChannel(group.JoinChannelID)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, groupMessages...)
+	}
+
+	channels := make(map[string]*discordgo.Channel, len(guildChannels))
+	for _, channel := range guildChannels {
+		channels[channel.ID] = channel
+	}
+
+	roleIDs := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		roleIDs[role.ID] = true
+	}
+
+	// join embeds per channel, newest first
+	var joinEmbeds []*discordgo.Message
+	embeds := make(map[string][]*discordgo.Message)
+	for _, message := range messages {
+		if message.Author == nil || message.Author.ID != discordClient.State.User.ID {
+			continue
+		}
+
+		channelID, err := h.EmbedChannelID(message)
+		if err != nil {
+			continue
+		}
+
+		joinEmbeds = append(joinEmbeds, message)
+		embeds[channelID] = append(embeds[channelID], message)
+	}
+
+	// in directory mode only the channels that do not fit in the directory of their group have a join embed
+	var overflow map[string]bool
+	if directory != nil {
+		grouped, err := groupChannels(guildInfo)
+		if err != nil {
+			return nil, err
+		}
+		overflow = directoryOverflow(grouped)
+	}
+
+	recorded := make(map[string]bool, len(records))
+	announced := make(map[string]bool, len(records))
+
+	for _, joinableChannel := range records {
+		recorded[joinableChannel.ChannelID] = true
+
+		channel, found := channels[joinableChannel.ChannelID]
+		if !found {
+			issues = append(issues, missingChannelIssue(guildInfo, joinableChannel, roleIDs[joinableChannel.RoleID]))
+			continue
+		}
+
+		_, active := categories[channel.ParentID]
+
+		if !roleIDs[joinableChannel.RoleID] {
+			issues = append(issues, missingRoleIssue(guildInfo, joinableChannel, channel, active))
+		}
+
+		group, found := groupsByName[joinableChannel.GroupName]
+		if (directory != nil && !overflow[channel.ID]) || !active || !found {
+			continue
+		}
+
+		announced[channel.ID] = true
+		issues = append(issues, embedIssues(guildInfo, group, joinableChannel, channel, embeds[channel.ID])...)
+	}
+
+	for _, channel := range guildChannels {
+		groupName, active := categories[channel.ParentID]
+		if _, joinable := joinableChannelTypes[channel.Type]; !joinable || !active || recorded[channel.ID] {
+			continue
+		}
+
+		group, found := groupsByName[groupName]
+		if !found {
+			continue
+		}
+
+		issues = append(issues, unrecordedChannelIssue(guildInfo, group, channel, roles))
+	}
+
+	for _, message := range joinEmbeds {
+		channelID, _ := h.EmbedChannelID(message)
+		if !announced[channelID] {
+			issues = append(issues, staleEmbedIssue(message, channelID))
+		}
+	}
+
+	return issues, nil
+}
+
+// missingChannelIssue is a recorded channel that was deleted by hand. Its role and records are left behind.
+func missingChannelIssue(guildInfo *m.GuildInformation, joinableChannel m.JoinableChannel, roleFound bool) consistencyIssue {
+	description := fmt.Sprintf("channel %s was deleted, its records will be removed", joinableChannel.ChannelID)
+	if roleFound {
+		description = fmt.Sprintf("role <@&%s> has no channel anymore, the role and its records will be removed", joinableChannel.RoleID)
+	}
+
+	return consistencyIssue{
+		description: description,
+		repair: func() error {
+			// a join embed that is left behind is reported as a stale embed
+			if roleFound {
+				err := c.Roles.DeleteRole(guildInfo.GuildID, joinableChannel.RoleID)
+				if err != nil {
+					return fmt.Errorf("unable to delete role: %s", err)
+				}
+			}
+
+			deleteJoinableChannelRecords(joinableChannel.ChannelID, joinableChannel.ChannelID)
+
+			return nil
+		},
+	}
+}
+
+// missingRoleIssue is a recorded channel whose role was deleted by hand. A new role is created, which means
+// the members of the channel have to join again.
+func missingRoleIssue(guildInfo *m.GuildInformation, joinableChannel m.JoinableChannel, channel *discordgo.Channel, active bool) consistencyIssue {
+	return consistencyIssue{
+		description: fmt.Sprintf("channel %s has no role, a new role will be created and members have to join again", channel.Mention()),
+		repair: func() error {
+			role, err := c.Roles.CreateRole(guildInfo.GuildID, &discordgo.RoleParams{
+				Name:        channel.Name,
+				Hoist:       &falseBool,
+				Mentionable: &falseBool,
+			})
+			if err != nil {
+				return err
+			}
+
+			permissions := joinablePermissions(guildInfo, role.ID, channel.Type)
+			if !active {
+				permissions = archivedPermissions(guildInfo, role.ID, channel.Type)
+			}
+
+			_, err = c.Channels.MoveChannel(channel, channel.ParentID, permissions)
+			if err != nil {
+				return err
+			}
+
+			joinableChannel.RoleID = role.ID
+
+			return c.DataStore.CreateJoinableChannel(joinableChannel)
+		},
+	}
+}
+
+// unrecordedChannelIssue is a channel in a category of a channel group that the bot does not know about.
+// A role with the same name is reused, like older versions matched them.
+func unrecordedChannelIssue(guildInfo *m.GuildInformation, group *m.ChannelGroup, channel *discordgo.Channel, roles []*discordgo.Role) consistencyIssue {
+	description := fmt.Sprintf("channel %s is not a joinable channel, a role will be created for it", channel.Mention())
+
+	pos, roleFound := h.FindChannelRole(roles, channel.Name)
+	if roleFound {
+		description = fmt.Sprintf("channel %s is not a joinable channel, it will be linked to role <@&%s>", channel.Mention(), roles[pos].ID)
+	}
+
+	return consistencyIssue{
+		description: description,
+		repair: func() error {
+			var roleID string
+
+			if roleFound {
+				roleID = roles[pos].ID
+			} else {
+				role, err := c.Roles.CreateRole(guildInfo.GuildID, &discordgo.RoleParams{
+					Name:        channel.Name,
+					Hoist:       &falseBool,
+					Mentionable: &falseBool,
+				})
+				if err != nil {
+					return err
+				}
+				roleID = role.ID
+			}
+
+			_, err := c.Channels.MoveChannel(channel, channel.ParentID, joinablePermissions(guildInfo, roleID, channel.Type))
+			if err != nil {
+				return err
+			}
+
+			createdAt, err := discordgo.SnowflakeTimestamp(channel.ID)
+			if err != nil {
+				return err
+			}
+
+			joinableChannel := m.JoinableChannel{
+				GuildID:   guildInfo.GuildID,
+				ChannelID: channel.ID,
+				RoleID:    roleID,
+				CreatedAt: createdAt,
+				GroupName: group.Name,
+			}
+
+			err = c.DataStore.CreateJoinableChannel(joinableChannel)
+			if err != nil {
+				return err
+			}
+
+			joinableChannel.EmbedMessageID, err = announceJoinableChannel(guildInfo, group, channel)
+			if err != nil {
+				return err
+			}
+
+			return c.DataStore.CreateJoinableChannel(joinableChannel)
+		},
+	}
+}
+
+// embedIssues compares the recorded join embed of an active channel with the embeds found in the join channels.
+// An unrecorded embed in the join channel of its group is adopted before a new one is posted, any other embed
+// of the channel is a duplicate.
+func embedIssues(guildInfo *m.GuildInformation, group *m.ChannelGroup, joinableChannel m.JoinableChannel, channel *discordgo.Channel, embeds []*discordgo.Message) []consistencyIssue {
+	var issues []consistencyIssue
+
+	keep := -1
+	for i, message := range embeds {
+		if message.ID == joinableChannel.EmbedMessageID {
+			keep = i
+		}
+	}
+
+	adopt := -1
+	for i, message := range embeds {
+		if message.ChannelID == group.JoinChannelID {
+			adopt = i
+			break
+		}
+	}
+
+	switch {
+	case keep < 0 && adopt >= 0:
+		keep = adopt
+		message := embeds[keep]
+
+		issues = append(issues, consistencyIssue{
+			description: fmt.Sprintf("the join embed of channel %s is not recorded, it will be recorded", channel.Mention()),
+			repair: func() error {
+				joinableChannel.EmbedMessageID = message.ID
+				return c.DataStore.CreateJoinableChannel(joinableChannel)
+			},
+		})
+	case keep < 0:
+		issues = append(issues, consistencyIssue{
+			description: fmt.Sprintf("channel %s has no join embed, a new one will be posted", channel.Mention()),
+			repair: func() error {
+				members, err := channelMembers(guildInfo, &joinableChannel)
+				if err != nil {
+					return err
+				}
+
+				message, err := c.Messages.JoinableChannelEmbed(guildInfo.GuildID, group.JoinChannelID, channel, members, joinableChannel.Capacity)
+				if err != nil {
+					return err
+				}
+
+				joinableChannel.EmbedMessageID = message.ID
+				return c.DataStore.CreateJoinableChannel(joinableChannel)
+			},
+		})
+	}
+
+	for i, message := range embeds {
+		if i == keep {
+			continue
+		}
+
+		message := message
+		issues = append(issues, consistencyIssue{
+			description: fmt.Sprintf("channel %s has a duplicate join embed, it will be deleted", channel.Mention()),
+			repair: func() error {
+				return c.Messages.DeleteMessage(message.ChannelID, message.ID)
+			},
+		})
+	}
+
+	return issues
+}
+
+// staleEmbedIssue is a join embed of a channel that was deleted, archived or is listed in the channel directory
+func staleEmbedIssue(message *discordgo.Message, channelID string) consistencyIssue {
+	return consistencyIssue{
+		description: fmt.Sprintf("the join embed of <#%s> does not belong to an active joinable channel, it will be deleted", channelID),
+		repair: func() error {
+			return c.Messages.DeleteMessage(message.ChannelID, message.ID)
+		},
+	}
+}
+
+// consistencyReport lists the issues, leaving out those that do not fit within the given length
+func consistencyReport(issues []consistencyIssue, length int) string {
+	var report strings.Builder
+
+	for i, issue := range issues {
+		line := fmt.Sprintf("• %s\n", issue.description)
+		more := fmt.Sprintf("… and %d more", len(issues)-i)
+
+		if report.Len()+len(line)+len(more) > length {
+			report.WriteString(more)
+			break
+		}
+
+		report.WriteString(line)
+	}
+
+	return report.String()
+}
+
+// repairGuild repairs the current issues of a guild and returns a summary of the results
+func repairGuild(guildInfo *m.GuildInformation) (string, error) {
+	var repaired, failed int
+	var failures []consistencyIssue
+
+	issues, err := checkConsistency(guildInfo)
+	if err != nil {
+		return "", err
+	}
+
+	for _, issue := range issues {
+		err = issue.repair()
+		if err != nil {
+			logger.Errorf("unable to repair issue in guild %s: %s: %s", guildInfo.GuildID, issue.description, err)
+			failures = append(failures, consistencyIssue{description: fmt.Sprintf("%s (%s)", issue.description, err)})
+			failed++
+			continue
+		}
+		repaired++
+	}
+
+	refreshChannelDirectory(guildInfo)
+
+	summary := fmt.Sprintf("Repaired %d issues, %d could not be repaired.", repaired, failed)
+	if failed > 0 {
+		summary = fmt.Sprintf("%s\n%s", summary, consistencyReport(failures, 3500))
+	}
+
+	return summary, nil
+}
+
+// checkAllConsistency reports the issues of every configured guild in the log and in its admin channel
+func checkAllConsistency() {
+	guildInfos, err := c.DataStore.GetAllGuildInfo()
+	if err != nil {
+		logger.Errorf("unable to retrieve guild information for the consistency check: %s", err)
+		return
+	}
+
+	for _, guildInfo := range guildInfos {
+		issues, err := checkConsistency(&guildInfo)
+		if err != nil {
+			logger.Errorf("unable to check the joinable channels of guild %s: %s", guildInfo.GuildID, err)
+			continue
+		}
+
+		if len(issues) == 0 {
+			continue
+		}
+
+		for _, issue := range issues {
+			logger.Warnf("guild %s: %s", guildInfo.GuildID, issue.description)
+		}
+
+		err = c.Messages.SendMessage(guildInfo.AdminChannelID, fmt.Sprintf("Found %d issues with the joinable channels, use /repair to fix them:\n%s", len(issues), consistencyReport(issues, 1800)))
+		if err != nil {
+			logger.Errorf("unable to report issues to the admin channel of guild %s: %s", guildInfo.GuildID, err)
+		}
+	}
+}
+
+func repairCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	// reading the whole join channel takes a while
+	err = h.SendInteractionAwaitResponse(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	issues, err := checkConsistency(guildInfo)
+	if err != nil {
+		h.EditInteractionResponse(s, i, fmt.Sprintf("unable to check joinable channels: %s", err))
+		return
+	}
+
+	if len(issues) == 0 {
+		h.EditInteractionResponse(s, i, "No issues found, the joinable channels are consistent.")
+		return
+	}
+
+	embed := discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Found %d issues with the joinable channels", len(issues)),
+		Type:        discordgo.EmbedTypeRich,
+		Description: consistencyReport(issues, 4000),
+	}
+
+	err = h.EditInteractionResponseComplex(s, i, []*discordgo.MessageEmbed{&embed}, []discordgo.MessageComponent{h.ConfirmButtons("repair", "Repair")})
+	if err != nil {
+		logger.Errorf("unable to send repair report to guild: %s", err)
+	}
+}
+
+func repairButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionEphemeralResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	err = h.SendInteractionAwaitUpdate(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	embed := i.Message.Embeds[0]
+
+	_, choice := h.ParseComponentID(i.MessageComponentData().CustomID)
+	if choice == "confirm" {
+		// the issues are checked again, they might have changed since the report
+		embed.Description, err = repairGuild(guildInfo)
+		if err != nil {
+			embed.Description = fmt.Sprintf("unable to check joinable channels: %s", err)
+		}
+	} else {
+		embed.Description = "Repair cancelled."
+	}
+
+	err = h.EditInteractionResponseComplex(s, i, []*discordgo.MessageEmbed{embed}, []discordgo.MessageComponent{})
+	if err != nil {
+		logger.Errorf("unable to send repair result to guild: %s", err)
+	}
+}
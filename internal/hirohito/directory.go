@@ -0,0 +1,594 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	"hirohito/internal/messages"
+	m "hirohito/internal/models"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// channelDirectory returns the channel directory of a guild, or nil when the guild does not use directory mode
+func channelDirectory(guildID string) (*m.ChannelDirectory, error) {
+	directory, err := c.DataStore.GetChannelDirectory(guildID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return directory, nil
+}
+
+// announceJoinableChannel makes a new or restored joinable channel visible in the join channel of its group and
+// returns the ID of its join embed. In directory mode the channel is added to the directory instead of getting an embed.
+func announceJoinableChannel(guildInfo *m.GuildInformation, group *m.ChannelGroup, channel *discordgo.Channel) (string, error) {
+	directory, err := channelDirectory(guildInfo.GuildID)
+	if err != nil {
+		return "", err
+	}
+
+	if directory == nil {
+		joinableChannel, err := joinableChannelRecord(channel)
+		if err != nil {
+			return "", err
+		}
+
+		members, err := channelMembers(guildInfo, joinableChannel)
+		if err != nil {
+			return "", err
+		}
+
+		message, err := c.Messages.JoinableChannelEmbed(guildInfo.GuildID, group.JoinChannelID, channel, members, joinableChannel.Capacity)
+		if err != nil {
+			return "", err
+		}
+		return message.ID, nil
+	}
+
+	refreshChannelDirectory(guildInfo)
+
+	// a channel that does not fit in the directory of its group got its own join embed
+	joinableChannel, err := joinableChannelRecord(channel)
+	if err != nil {
+		return "", err
+	}
+
+	return joinableChannel.EmbedMessageID, nil
+}
+
+// groupChannels returns the joinable channels of every group, indexed by the name of the group
+func groupChannels(guildInfo *m.GuildInformation) (map[string][]*discordgo.Channel, error) {
+	channels, err := joinableChannels(guildInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := joinableChannelRecords(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	grouped := make(map[string][]*discordgo.Channel)
+	for _, channel := range channels {
+		groupName := records[channel.ID].GroupName
+		grouped[groupName] = append(grouped[groupName], channel)
+	}
+
+	return grouped, nil
+}
+
+// directoryOverflow returns the IDs of the channels that do not fit in the directory of their group.
+// These channels keep their own join embed in directory mode.
+func directoryOverflow(grouped map[string][]*discordgo.Channel) map[string]bool {
+	overflow := make(map[string]bool)
+
+	for _, channels := range grouped {
+		if len(channels) <= messages.DirectoryCapacity {
+			continue
+		}
+
+		for _, channel := range channels[messages.DirectoryCapacity:] {
+			overflow[channel.ID] = true
+		}
+	}
+
+	return overflow
+}
+
+// syncOverflowEmbeds posts join embeds for the channels of a group that do not fit in its directory, and
+// deletes the join embeds of the channels that are listed in it
+func syncOverflowEmbeds(guildInfo *m.GuildInformation, group *m.ChannelGroup, channels []*discordgo.Channel, records map[string]m.JoinableChannel) {
+	for index, channel := range channels {
+		joinableChannel := records[channel.ID]
+		listed := index < messages.DirectoryCapacity
+
+		switch {
+		case listed && joinableChannel.EmbedMessageID != "":
+			err := c.Messages.DeleteMessage(group.JoinChannelID, joinableChannel.EmbedMessageID)
+			if err != nil {
+				logger.Warnf("unable to delete join embed of channel %s in guild %s: %s", channel.Name, guildInfo.GuildID, err)
+			}
+
+			joinableChannel.EmbedMessageID = ""
+		case !listed && joinableChannel.EmbedMessageID == "":
+			members, err := channelMembers(guildInfo, &joinableChannel)
+			if err != nil {
+				logger.Errorf("unable to post join embed of channel %s in guild %s: %s", channel.Name, guildInfo.GuildID, err)
+				continue
+			}
+
+			message, err := c.Messages.JoinableChannelEmbed(guildInfo.GuildID, group.JoinChannelID, channel, members, joinableChannel.Capacity)
+			if err != nil {
+				logger.Errorf("unable to post join embed of channel %s in guild %s: %s", channel.Name, guildInfo.GuildID, err)
+				continue
+			}
+
+			joinableChannel.EmbedMessageID = message.ID
+		default:
+			continue
+		}
+
+		err := c.DataStore.CreateJoinableChannel(joinableChannel)
+		if err != nil {
+			logger.Errorf("unable to save joinable channel %s of guild %s: %s", channel.Name, guildInfo.GuildID, err)
+		}
+	}
+}
+
+// postGroupDirectory posts a new directory message in the join channel of a group and saves it
+func postGroupDirectory(group *m.ChannelGroup, channels []*discordgo.Channel) error {
+	message, err := c.Messages.ChannelDirectoryMessage(group.JoinChannelID, channels)
+	if err != nil {
+		return err
+	}
+
+	group.DirectoryMessageID = message.ID
+
+	return saveGroupDirectory(group)
+}
+
+// refreshChannelDirectory regenerates the directory messages of all groups after joinable channels changed.
+// A directory message that was removed by hand is posted again. Channels that do not fit in the directory
+// of their group get their own join embed instead.
+func refreshChannelDirectory(guildInfo *m.GuildInformation) {
+	directory, err := channelDirectory(guildInfo.GuildID)
+	if err != nil {
+		logger.Errorf("unable to retrieve channel directory of guild %s: %s", guildInfo.GuildID, err)
+		return
+	}
+
+	if directory == nil {
+		return
+	}
+
+	groups, err := channelGroups(guildInfo)
+	if err != nil {
+		logger.Errorf("unable to retrieve channel groups of guild %s: %s", guildInfo.GuildID, err)
+		return
+	}
+
+	grouped, err := groupChannels(guildInfo)
+	if err != nil {
+		logger.Errorf("unable to retrieve joinable channels of guild %s: %s", guildInfo.GuildID, err)
+		return
+	}
+
+	records, err := joinableChannelRecords(guildInfo.GuildID)
+	if err != nil {
+		logger.Errorf("unable to retrieve joinable channels of guild %s: %s", guildInfo.GuildID, err)
+		return
+	}
+
+	for _, group := range groups {
+		channels := grouped[group.Name]
+
+		syncOverflowEmbeds(guildInfo, &group, channels, records)
+
+		if group.DirectoryMessageID != "" {
+			err = c.Messages.EditChannelDirectory(group.JoinChannelID, group.DirectoryMessageID, channels)
+			if err == nil {
+				continue
+			}
+
+			logger.Warnf("unable to edit channel directory of group %s in guild %s, posting a new one: %s", group.Name, guildInfo.GuildID, err)
+		}
+
+		err = postGroupDirectory(&group, channels)
+		if err != nil {
+			logger.Errorf("unable to post channel directory of group %s in guild %s: %s", group.Name, guildInfo.GuildID, err)
+		}
+	}
+}
+
+// announceUnlistedChannels posts the join embeds of the channels that were only listed in the directory,
+// after directory mode was disabled. It returns the number of embeds that could not be posted.
+func announceUnlistedChannels(guildInfo *m.GuildInformation, groups []m.ChannelGroup) (int, error) {
+	var failed int
+
+	grouped, err := groupChannels(guildInfo)
+	if err != nil {
+		return 0, err
+	}
+
+	records, err := joinableChannelRecords(guildInfo.GuildID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, group := range groups {
+		for _, channel := range grouped[group.Name] {
+			joinableChannel := records[channel.ID]
+			if joinableChannel.EmbedMessageID != "" {
+				continue
+			}
+
+			joinableChannel.EmbedMessageID, err = announceJoinableChannel(guildInfo, &group, channel)
+			if err == nil {
+				err = c.DataStore.CreateJoinableChannel(joinableChannel)
+			}
+			if err != nil {
+				logger.Errorf("unable to post join embed of channel %s in guild %s: %s", channel.Name, guildInfo.GuildID, err)
+				failed++
+			}
+		}
+	}
+
+	return failed, nil
+}
+
+func channelDirectoryCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var enabled bool
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "enabled":
+			enabled = option.BoolValue()
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	directory, err := channelDirectory(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	groups, err := channelGroups(guildInfo)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	if !enabled {
+		if directory == nil {
+			h.SendInteractionResponse(s, i, "Directory mode is not enabled.")
+			return
+		}
+
+		// posting the join embeds of every channel can take longer than an interaction allows
+		err = h.SendInteractionAwaitResponse(s, i, "")
+		if err != nil {
+			logger.Errorf("unable to send response to guild: %s", err)
+			return
+		}
+
+		err = c.DataStore.DeleteChannelDirectory(i.GuildID)
+		if err != nil {
+			h.EditInteractionResponse(s, i, err.Error())
+			return
+		}
+
+		for _, group := range groups {
+			if group.DirectoryMessageID == "" {
+				continue
+			}
+
+			err = c.Messages.DeleteMessage(group.JoinChannelID, group.DirectoryMessageID)
+			if err != nil {
+				logger.Warnf("unable to delete channel directory message of group %s in guild %s: %s", group.Name, i.GuildID, err)
+			}
+
+			if group.Name == "" {
+				continue
+			}
+
+			group.DirectoryMessageID = ""
+
+			err = c.DataStore.CreateChannelGroup(group)
+			if err != nil {
+				logger.Errorf("unable to save channel group %s of guild %s: %s", group.Name, i.GuildID, err)
+			}
+		}
+
+		failed, err := announceUnlistedChannels(guildInfo, groups)
+		if err != nil {
+			h.EditInteractionResponse(s, i, fmt.Sprintf("Directory mode disabled, but the join embeds could not be posted. Use /repair to post them: %s", err))
+			return
+		}
+
+		if failed > 0 {
+			h.EditInteractionResponse(s, i, fmt.Sprintf("Directory mode disabled, but %d join embeds could not be posted. Use /repair to post them.", failed))
+			return
+		}
+
+		h.EditInteractionResponse(s, i, "Directory mode disabled. Every joinable channel has its own join embed again.")
+		return
+	}
+
+	if directory != nil {
+		h.SendInteractionResponse(s, i, "Directory mode is already enabled.")
+		return
+	}
+
+	grouped, err := groupChannels(guildInfo)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	// the directory of the default group enables directory mode, so it is posted first
+	for _, group := range groups {
+		err = postGroupDirectory(&group, grouped[group.Name])
+		if err != nil {
+			h.SendInteractionResponse(s, i, fmt.Sprintf("unable to post channel directory: %s", err))
+			return
+		}
+	}
+
+	h.SendInteractionResponse(s, i, "Directory mode enabled. New joinable channels are added to the directory instead of getting their own join embed.")
+}
+
+// findSelectMenu returns the select menu with the given custom ID in a message, or nil when there is none
+func findSelectMenu(message *discordgo.Message, customID string) *discordgo.SelectMenu {
+	for _, component := range message.Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+
+		for _, rowComponent := range row.Components {
+			menu, ok := rowComponent.(*discordgo.SelectMenu)
+			if ok && menu.CustomID == customID {
+				return menu
+			}
+		}
+	}
+
+	return nil
+}
+
+// selectMenuOptions returns the channel IDs offered by the select menu with the given custom ID
+func selectMenuOptions(message *discordgo.Message, customID string) []string {
+	var channelIDs []string
+
+	menu := findSelectMenu(message, customID)
+	if menu == nil {
+		return nil
+	}
+
+	for _, option := range menu.Options {
+		channelIDs = append(channelIDs, option.Value)
+	}
+
+	return channelIDs
+}
+
+// personalDirectory builds a copy of a directory menu for a single user, with the channels
+// the user is a member of preselected
+func personalDirectory(menu *discordgo.SelectMenu, customID string, records map[string]m.JoinableChannel, userRoles []string) ([]*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	personal := *menu
+	personal.CustomID = customID
+	personal.Options = make([]discordgo.SelectMenuOption, len(menu.Options))
+
+	for index, option := range menu.Options {
+		_, member := h.FindRoleID(userRoles, records[option.Value].RoleID)
+		option.Default = member
+		personal.Options[index] = option
+	}
+
+	embed := discordgo.MessageEmbed{
+		Title:       "Your channels",
+		Type:        discordgo.EmbedTypeRich,
+		Description: "The channels you are in are selected below. Deselect a channel to leave it, or select one to join it.",
+	}
+
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{personal},
+		},
+	}
+
+	return []*discordgo.MessageEmbed{&embed}, components
+}
+
+// applyDirectorySelection joins the selected channels of a directory menu the user is not in yet.
+// When leave is set, the unselected channels of the menu the user is in are left as well.
+func applyDirectorySelection(s *discordgo.Session, guildInfo *m.GuildInformation, user *discordgo.User, channelIDs []string, values []string, leave bool) ([]string, error) {
+	var results []string
+
+	records, err := joinableChannelRecords(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	userRoles, err := c.Users.GetUserRoles(guildInfo.GuildID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	selectedIDs := make(map[string]bool, len(values))
+	for _, value := range values {
+		selectedIDs[value] = true
+	}
+
+	for _, channelID := range channelIDs {
+		channel, err := s.Channel(channelID)
+		if err != nil {
+			logger.Warnf("channel directory of guild %s lists unknown channel %s: %s", guildInfo.GuildID, channelID, err)
+			continue
+		}
+
+		joinableChannel, found := records[channelID]
+		if !found {
+			logger.Errorf("channel directory of guild %s lists channel %s, which is not joinable", guildInfo.GuildID, channel.Name)
+			continue
+		}
+
+		selected := selectedIDs[channelID]
+		_, member := h.FindRoleID(userRoles, joinableChannel.RoleID)
+
+		var result string
+		switch {
+		case selected && !member:
+			result, err = joinJoinableChannel(guildInfo, user, channel)
+		case !selected && member && leave:
+			result, err = leaveJoinableChannel(guildInfo, user, channel)
+		default:
+			continue
+		}
+
+		if err != nil {
+			logger.Error(err)
+			result = fmt.Sprintf("Something went wrong with %s. Please contact an admin.", channel.Mention())
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// directoryResponse reports the outcome of a directory selection together with the personal menu of the user
+func directoryResponse(s *discordgo.Session, i *discordgo.InteractionCreate, menu *discordgo.SelectMenu, customID string, results []string) {
+	records, err := joinableChannelRecords(i.GuildID)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	userRoles, err := c.Users.GetUserRoles(i.GuildID, i.Member.User.ID)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	embeds, components := personalDirectory(menu, customID, records, userRoles)
+
+	if len(results) == 0 {
+		results = append(results, "Nothing changed.")
+	}
+
+	embeds[0].Description = strings.Join(results, "\n") + "\n\n" + embeds[0].Description
+
+	err = h.EditInteractionResponseComplex(s, i, embeds, components)
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+	}
+}
+
+// directorySelect joins the channels picked in a shared directory menu. The shared menu cannot show
+// what every user is in, so it never leaves channels. Instead the user gets a personal copy of the menu
+// with their channels preselected, in which deselecting a channel leaves it.
+func directorySelect(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	data := i.MessageComponentData()
+
+	menu := findSelectMenu(i.Message, data.CustomID)
+	if menu == nil {
+		h.SendInteractionEphemeralResponse(s, i, "This channel directory is outdated.")
+		return
+	}
+
+	err = h.SendInteractionAwaitEphemeralResponse(s, i)
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	results, err := applyDirectorySelection(s, guildInfo, i.Member.User, selectMenuOptions(i.Message, data.CustomID), data.Values, false)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	_, index := h.ParseComponentID(data.CustomID)
+	directoryResponse(s, i, menu, h.ComponentID("mydirectory", index), results)
+}
+
+// personalDirectorySelect makes the memberships of a user match their selection in a personal directory menu
+func personalDirectorySelect(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	data := i.MessageComponentData()
+
+	menu := findSelectMenu(i.Message, data.CustomID)
+	if menu == nil {
+		h.SendInteractionEphemeralResponse(s, i, "This channel directory is outdated.")
+		return
+	}
+
+	err = h.SendInteractionAwaitUpdate(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	results, err := applyDirectorySelection(s, guildInfo, i.Member.User, selectMenuOptions(i.Message, data.CustomID), data.Values, true)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	directoryResponse(s, i, menu, data.CustomID, results)
+}
@@ -0,0 +1,228 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// how long before its expiry a reminder is posted in an expiring channel
+const expiryReminder = 24 * time.Hour
+
+// expiryLayouts are the date formats accepted by the expires option, interpreted as UTC
+var expiryLayouts = []string{"2006-01-02 15:04", "2006-01-02"}
+
+// parseExpiry turns the expires option into a point in time. It accepts a duration from now,
+// such as 36h or 7d, or a date with an optional time of day in UTC.
+func parseExpiry(now time.Time, value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	var expiresAt time.Time
+
+	if strings.HasSuffix(value, "d") {
+		count, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is not a valid number of days", value)
+		}
+		expiresAt = now.Add(days(count))
+	} else if duration, err := time.ParseDuration(value); err == nil {
+		expiresAt = now.Add(duration)
+	} else {
+		for _, layout := range expiryLayouts {
+			if expiresAt, err = time.Parse(layout, value); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is not a valid expiry. Use a duration such as 36h or 7d, or a date such as 2024-05-01 18:00 (UTC)", value)
+		}
+	}
+
+	if !expiresAt.After(now) {
+		return time.Time{}, errors.New("the expiry has to be in the future")
+	}
+
+	return expiresAt, nil
+}
+
+// expiryTask reminds the members of expiring channels a day before the expiry and
+// deletes or archives the channels that have expired
+func expiryTask(now time.Time) {
+	joinableChannels, err := c.DataStore.GetExpiringJoinableChannels()
+	if err != nil {
+		logger.Errorf("unable to retrieve expiring channels: %s", err)
+		return
+	}
+
+	for i := range joinableChannels {
+		joinableChannel := &joinableChannels[i]
+
+		// the list is sorted by expiry, nothing further down needs attention yet
+		if joinableChannel.ExpiresAt.Sub(now) > expiryReminder {
+			break
+		}
+
+		guildInfo, err := checkGuildSetup(joinableChannel.GuildID)
+		if err != nil {
+			logger.Warnf("skipping expiry of channel %s: %s", joinableChannel.ChannelID, err)
+			continue
+		}
+
+		channel, err := discordClient.Channel(joinableChannel.ChannelID)
+		if h.IsUnknownChannel(err) {
+			// the channel was deleted by hand, its records would otherwise be retried forever
+			logger.Infof("expiring channel %s in guild %s no longer exists, removing its role and records", joinableChannel.ChannelID, guildInfo.GuildID)
+
+			err = removeDeletedChannel(guildInfo, joinableChannel)
+			if err != nil {
+				logger.Errorf("unable to clean up deleted channel %s in guild %s: %s", joinableChannel.ChannelID, guildInfo.GuildID, err)
+			}
+			continue
+		}
+		if err != nil {
+			logger.Errorf("unable to retrieve expiring channel %s: %s", joinableChannel.ChannelID, err)
+			continue
+		}
+
+		if now.Before(joinableChannel.ExpiresAt) {
+			if joinableChannel.ExpiryReminded == 0 {
+				remindExpiry(channel, joinableChannel)
+			}
+			continue
+		}
+
+		summary, err := expireChannel(now, guildInfo, channel, joinableChannel)
+		if err != nil {
+			logger.Errorf("unable to expire channel %s in guild %s: %s", channel.Name, guildInfo.GuildID, err)
+			continue
+		}
+
+		logger.Infof("expired channel %s in guild %s", channel.Name, guildInfo.GuildID)
+
+		err = c.Messages.SendMessage(guildInfo.AdminChannelID, summary)
+		if err != nil {
+			logger.Errorf("unable to post expiry summary to guild %s: %s", guildInfo.GuildID, err)
+		}
+	}
+}
+
+func remindExpiry(channel *discordgo.Channel, joinableChannel *m.JoinableChannel) {
+	// nothing can be posted in a forum itself, its members only miss out on the reminder
+	if channel.Type != discordgo.ChannelTypeGuildForum {
+		err := c.Messages.ExpiryReminderMessage(channel.ID, joinableChannel.ExpiresAt, joinableChannel.ExpiryArchive == 1)
+		if err != nil {
+			logger.Errorf("unable to post expiry reminder in channel %s: %s", channel.Name, err)
+			return
+		}
+	}
+
+	joinableChannel.ExpiryReminded = 1
+
+	err := c.DataStore.CreateJoinableChannel(*joinableChannel)
+	if err != nil {
+		logger.Errorf("unable to record expiry reminder of channel %s: %s", channel.Name, err)
+	}
+}
+
+// removeDeletedChannel removes the join embed, the role and the records of a joinable channel that was
+// deleted by hand. The records are kept when the role can not be deleted, so it is not lost for good.
+func removeDeletedChannel(guildInfo *m.GuildInformation, joinableChannel *m.JoinableChannel) error {
+	if joinableChannel.EmbedMessageID != "" {
+		group, err := channelGroup(guildInfo, joinableChannel.GroupName)
+		if err == nil {
+			err = c.Messages.DeleteMessage(group.JoinChannelID, joinableChannel.EmbedMessageID)
+		}
+		if err != nil {
+			logger.Warnf("unable to delete join embed of deleted channel %s: %s", joinableChannel.ChannelID, err)
+		}
+	}
+
+	err := c.Roles.DeleteRole(guildInfo.GuildID, joinableChannel.RoleID)
+	if err != nil && !h.IsUnknownRole(err) {
+		return fmt.Errorf("unable to delete role: %s", err)
+	}
+
+	deleteJoinableChannelRecords(joinableChannel.ChannelID, joinableChannel.ChannelID)
+
+	refreshChannelDirectory(guildInfo)
+
+	return nil
+}
+
+// expireChannel archives or deletes an expired channel and returns a summary for the admin channel.
+// Channels that should be archived are deleted when the guild has no archiving category.
+func expireChannel(now time.Time, guildInfo *m.GuildInformation, channel *discordgo.Channel, joinableChannel *m.JoinableChannel) (string, error) {
+	if joinableChannel.ExpiryArchive == 1 {
+		archivingInfo, err := c.DataStore.GetArchivingInfo(guildInfo.GuildID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+
+		if archivingInfo != nil && archivingInfo.ArchivingCategoryID != "" {
+			return archiveExpiredChannel(now, guildInfo, archivingInfo, channel)
+		}
+
+		logger.Warnf("no archiving category configured for guild %s, deleting expired channel %s instead", guildInfo.GuildID, channel.Name)
+	}
+
+	transcriptResult, err := removeJoinableChannel(guildInfo, channel, joinableChannel, "expired")
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("⌛ Expired channel #%s: %s, channel deleted.", channel.Name, transcriptResult), nil
+}
+
+func archiveExpiredChannel(now time.Time, guildInfo *m.GuildInformation, archivingInfo *m.ArchivingInformation, channel *discordgo.Channel) (string, error) {
+	if channel.ParentID != archivingInfo.ArchivingCategoryID {
+		err := archiveJoinableChannel(now, guildInfo, archivingInfo, channel)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// archiving rewrites the record, so the expiry is cleared on a fresh copy
+	joinableChannel, err := joinableChannelRecord(channel)
+	if err != nil {
+		return "", err
+	}
+
+	joinableChannel.ExpiresAt = time.Time{}
+	joinableChannel.ExpiryReminded = 0
+
+	err = c.DataStore.CreateJoinableChannel(*joinableChannel)
+	if err != nil {
+		return "", fmt.Errorf("channel archived, but its expiry could not be cleared: %s", err)
+	}
+
+	return fmt.Sprintf("⌛ Expired channel %s: channel archived.", channel.Mention()), nil
+}
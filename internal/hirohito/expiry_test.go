@@ -0,0 +1,70 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "7d", want: now.Add(7 * 24 * time.Hour)},
+		{value: " 1d ", want: now.Add(24 * time.Hour)},
+		{value: "36h", want: now.Add(36 * time.Hour)},
+		{value: "90m", want: now.Add(90 * time.Minute)},
+		{value: "2024-06-01", want: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "2024-05-01 18:30", want: time.Date(2024, 5, 1, 18, 30, 0, 0, time.UTC)},
+		{value: "0d", wantErr: true},
+		{value: "-2h", wantErr: true},
+		{value: "2024-04-30", wantErr: true},
+		{value: "xd", wantErr: true},
+		{value: "next week", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseExpiry(now, tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseExpiry(%q) = %s, want an error", tt.value, got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseExpiry(%q) returned error: %s", tt.value, err)
+			}
+
+			if !got.Equal(tt.want) {
+				t.Errorf("parseExpiry(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,378 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxCategoryChannels is the number of channels discord allows in a single category
+const maxCategoryChannels = 50
+
+// defaultGroup returns the group configured with the setup command. It has no name and its
+// directory is kept in the channel directory of the guild.
+func defaultGroup(guildInfo *m.GuildInformation) (*m.ChannelGroup, error) {
+	group := m.ChannelGroup{
+		GuildID:       guildInfo.GuildID,
+		JoinChannelID: guildInfo.JoinChannelID,
+	}
+
+	directory, err := channelDirectory(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	if directory != nil {
+		group.DirectoryMessageID = directory.MessageID
+	}
+
+	return &group, nil
+}
+
+// channelGroup returns the group with the given name, or the default group when the name is empty
+func channelGroup(guildInfo *m.GuildInformation, name string) (*m.ChannelGroup, error) {
+	if name == "" {
+		return defaultGroup(guildInfo)
+	}
+
+	group, err := c.DataStore.GetChannelGroup(guildInfo.GuildID, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("there is no channel group named %s", name)
+		}
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// channelGroups returns the default group followed by the named groups of a guild
+func channelGroups(guildInfo *m.GuildInformation) ([]m.ChannelGroup, error) {
+	group, err := defaultGroup(guildInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := c.DataStore.GetChannelGroups(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]m.ChannelGroup{*group}, groups...), nil
+}
+
+// saveGroupDirectory stores the directory message of a group
+func saveGroupDirectory(group *m.ChannelGroup) error {
+	if group.Name == "" {
+		return c.DataStore.CreateChannelDirectory(m.ChannelDirectory{
+			GuildID:   group.GuildID,
+			ChannelID: group.JoinChannelID,
+			MessageID: group.DirectoryMessageID,
+		})
+	}
+
+	return c.DataStore.CreateChannelGroup(*group)
+}
+
+// joinableCategories returns the categories holding active joinable channels, mapped to the name of their group
+func joinableCategories(guildInfo *m.GuildInformation) (map[string]string, error) {
+	groupCategories, err := c.DataStore.GetGroupCategories(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := map[string]string{guildInfo.JoinableChannelsCategoryID: ""}
+	for _, category := range groupCategories {
+		categories[category.CategoryID] = category.GroupName
+	}
+
+	return categories, nil
+}
+
+// isJoinableCategory reports whether channels in the category can be joined, i.e. are not archived
+func isJoinableCategory(guildInfo *m.GuildInformation, categoryID string) (bool, error) {
+	categories, err := joinableCategories(guildInfo)
+	if err != nil {
+		return false, err
+	}
+
+	_, found := categories[categoryID]
+
+	return found, nil
+}
+
+// groupCategory returns a category of the group with room for another channel. When all categories
+// of the group are full, an overflow category is created below the last one.
+func groupCategory(guildInfo *m.GuildInformation, group *m.ChannelGroup) (string, error) {
+	var categoryIDs []string
+
+	if group.Name == "" {
+		categoryIDs = append(categoryIDs, guildInfo.JoinableChannelsCategoryID)
+	}
+
+	groupCategories, err := c.DataStore.GetGroupCategories(guildInfo.GuildID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, category := range groupCategories {
+		if category.GroupName == group.Name {
+			categoryIDs = append(categoryIDs, category.CategoryID)
+		}
+	}
+
+	guildChannels, err := discordClient.GuildChannels(guildInfo.GuildID)
+	if err != nil {
+		return "", err
+	}
+
+	categories := make(map[string]*discordgo.Channel)
+	children := make(map[string]int)
+	for _, channel := range guildChannels {
+		if channel.Type == discordgo.ChannelTypeGuildCategory {
+			categories[channel.ID] = channel
+		}
+		children[channel.ParentID]++
+	}
+
+	var first, last *discordgo.Channel
+	for _, categoryID := range categoryIDs {
+		category, found := categories[categoryID]
+		if !found {
+			continue
+		}
+
+		if children[categoryID] < maxCategoryChannels {
+			return categoryID, nil
+		}
+
+		if first == nil {
+			first = category
+		}
+		last = category
+	}
+
+	if last == nil {
+		return "", fmt.Errorf("the categories of channel group %s no longer exist", group.Name)
+	}
+
+	name := fmt.Sprintf("%s %d", first.Name, len(categoryIDs)+1)
+
+	category, err := c.Channels.CreateCategory(guildInfo.GuildID, name, last.Position+1, last.PermissionOverwrites)
+	if err != nil {
+		return "", fmt.Errorf("unable to create overflow category: %s", err)
+	}
+
+	err = c.DataStore.CreateGroupCategory(m.GroupCategory{
+		GuildID:    guildInfo.GuildID,
+		GroupName:  group.Name,
+		CategoryID: category.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	logger.Infof("created overflow category %s in guild %s", name, guildInfo.GuildID)
+
+	return category.ID, nil
+}
+
+func createChannelGroup(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name, joinChannelID, categoryID string
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "name":
+			name = strings.ToLower(option.StringValue())
+		case "joinchannelid":
+			joinChannelID = option.StringValue()
+		case "categoryid":
+			categoryID = option.StringValue()
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	if _, err := channelGroup(guildInfo, name); err == nil {
+		h.SendInteractionResponse(s, i, "Requested group name already exists.")
+		return
+	}
+
+	joinChannel, err := s.Channel(joinChannelID)
+	if err != nil || joinChannel.GuildID != i.GuildID || joinChannel.Type != discordgo.ChannelTypeGuildText {
+		h.SendInteractionResponse(s, i, "the join channel must be a text channel of this guild")
+		return
+	}
+
+	category, err := s.Channel(categoryID)
+	if err != nil || category.GuildID != i.GuildID || category.Type != discordgo.ChannelTypeGuildCategory {
+		h.SendInteractionResponse(s, i, "the category must be a category of this guild")
+		return
+	}
+
+	if joinable, _ := isJoinableCategory(guildInfo, categoryID); joinable {
+		h.SendInteractionResponse(s, i, "the category already belongs to a channel group")
+		return
+	}
+
+	group := m.ChannelGroup{
+		GuildID:       i.GuildID,
+		Name:          name,
+		JoinChannelID: joinChannelID,
+	}
+
+	err = c.DataStore.CreateChannelGroup(group)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	err = c.DataStore.CreateGroupCategory(m.GroupCategory{
+		GuildID:    i.GuildID,
+		GroupName:  name,
+		CategoryID: categoryID,
+	})
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	refreshChannelDirectory(guildInfo)
+
+	h.SendInteractionResponse(s, i, fmt.Sprintf("Channel group %s created. Its channels are announced in %s.", name, joinChannel.Mention()))
+}
+
+func deleteChannelGroup(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name string
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "name":
+			name = strings.ToLower(option.StringValue())
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	group, err := c.DataStore.GetChannelGroup(i.GuildID, name)
+	if err != nil {
+		h.SendInteractionResponse(s, i, fmt.Sprintf("there is no channel group named %s", name))
+		return
+	}
+
+	records, err := c.DataStore.GetJoinableChannels(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	for _, joinableChannel := range records {
+		if joinableChannel.GroupName == name {
+			h.SendInteractionResponse(s, i, "The group still has joinable channels. Delete them first.")
+			return
+		}
+	}
+
+	err = c.DataStore.DeleteChannelGroup(i.GuildID, name)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	if group.DirectoryMessageID != "" {
+		err = c.Messages.DeleteMessage(group.JoinChannelID, group.DirectoryMessageID)
+		if err != nil {
+			logger.Warnf("unable to delete channel directory of group %s: %s", name, err)
+		}
+	}
+
+	h.SendInteractionResponse(s, i, fmt.Sprintf("Channel group %s deleted. Its categories and join channel were left in place.", name))
+}
+
+// groupAutocomplete suggests the named channel groups of the guild matching what was typed so far
+func groupAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var typed string
+
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Focused {
+			typed = strings.ToLower(option.StringValue())
+		}
+	}
+
+	groups, err := c.DataStore.GetChannelGroups(i.GuildID)
+	if err != nil {
+		logger.Errorf("unable to retrieve channel groups of guild %s: %s", i.GuildID, err)
+	}
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	for _, group := range groups {
+		if len(choices) == 25 {
+			break
+		}
+
+		if strings.HasPrefix(group.Name, typed) {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  group.Name,
+				Value: group.Name,
+			})
+		}
+	}
+
+	err = h.SendAutocompleteResponse(s, i, choices)
+	if err != nil {
+		logger.Errorf("unable to send autocomplete response to guild: %s", err)
+	}
+}
@@ -23,13 +23,11 @@ package hirohito
 
 import (
 	c "hirohito/internal/config"
-	h "hirohito/internal/helpers"
-
-	"regexp"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+// reactionHandler keeps join embeds working that were posted before they had buttons
 func reactionHandler(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
 	guildInfo, err := checkGuildSetup(m.GuildID)
 	if err != nil {
@@ -46,72 +44,38 @@ func reactionHandler(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
 		return
 	}
 
-	message, err := s.ChannelMessage(m.ChannelID, m.MessageID)
-	if err != nil {
-		logger.Errorf("error retrieving message from reaction: %s", err)
-		return
-	}
-
-	exp, err := regexp.Compile(`^<#([0-9].*)>`)
+	joinableChannel, err := c.DataStore.GetJoinableChannelByEmbed(m.MessageID)
 	if err != nil {
-		logger.Error("Unable to compile regex")
-	}
-
-	result := exp.FindStringSubmatch(message.Embeds[0].Fields[0].Value)
-	if len(result) < 1 {
-		logger.Error("unable to get channelid from embed")
+		logger.Errorf("error retrieving joinable channel of embed %s: %s", m.MessageID, err)
 		return
 	}
 
-	channel, err := s.Channel(result[1])
+	channel, err := s.Channel(joinableChannel.ChannelID)
 	if err != nil {
 		logger.Errorf("Unable to get channel: %s", err)
-	}
-
-	roleList, err := c.Roles.RetrieveRoles(m.GuildID)
-	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, err.Error())
-		return
-	}
-
-	i, found := h.FindChannelRole(roleList, channel.Name)
-	if !found {
-		logger.Error("Unable to find role of mentioned channel")
 		return
 	}
 
-	userRoles, err := c.Users.GetUserRoles(m.GuildID, m.UserID)
-	if err != nil {
-		logger.Errorf("Error getting user %s roles: %s", m.UserID, err)
-	}
+	var result string
 
 	switch m.Emoji.APIName() {
 	case "▶️":
-
-		if _, found := h.FindRoleID(userRoles, roleList[i].ID); found {
-			return
-		}
-
-		err = c.Users.AssignUserToRole(m.GuildID, m.UserID, roleList[i].ID)
-		if err != nil {
-			logger.Errorf("error assignng role %s to user %s. Error: %s", roleList[i].Name, m.UserID, err)
-			return
-		}
-
-		c.Messages.UserJoinedChannelMessage(m.GuildID, channel.ID, *m.Member.User)
+		result, err = joinJoinableChannel(guildInfo, m.Member.User, channel)
 	case "🚮":
+		result, err = leaveJoinableChannel(guildInfo, m.Member.User, channel)
+	default:
+		return
+	}
 
-		if _, found := h.FindRoleID(userRoles, roleList[i].ID); !found {
-			return
-		}
-
-		err = c.Users.RemoveUserFromRole(m.GuildID, m.UserID, roleList[i].ID)
-		if err != nil {
-			logger.Errorf("error removing role %s from user %s. Error: %s", roleList[i].Name, m.UserID, err)
-			return
-		}
+	if err != nil {
+		logger.Errorf("error handling reaction of user %s on channel %s: %s", m.UserID, channel.Name, err)
+		result = "Something went wrong. Please contact an admin."
+	}
 
-		c.Messages.UserLeftChannelMessage(m.GuildID, channel.ID, *m.Member.User)
+	// a reaction has no response of its own, so the outcome is sent as a DM
+	err = c.Messages.SendDirectMessage(m.UserID, result)
+	if err != nil {
+		logger.Warnf("unable to send outcome of reaction on channel %s to user %s (%s): %s", channel.Name, m.UserID, result, err)
 	}
 }
 
@@ -24,6 +24,9 @@ package hirohito
 import (
 	"context"
 	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -36,8 +39,34 @@ var (
 
 	started bool = false
 
-	// less typing by referencing
-	discordClient = c.Configuration.Discord.Client
+	// how often the archiver checks joinable channels for inactivity
+	archiveCheckInterval = time.Hour
+	// how often expiring channels are checked
+	expiryCheckInterval = 5 * time.Minute
+	// inactivity interval in days for guilds without archiving settings
+	defaultArchiveInterval    = 60
+	defaultArchiveWarningDays = 7
+	minArchiveInterval        = 1.0
+	minArchiveWarningDays     = 0.0
+	minArchiveOverride        = 0.0
+	minArchiveRetention       = 0.0
+
+	minCapacity         = 0.0
+	minPrerequisiteDays = 0.0
+
+	// how long the member counts used by announcements are trusted before the guild is counted again
+	memberCountTTL = 15 * time.Minute
+
+	// how often proposals are checked for a passed voting window
+	proposalCheckInterval = 5 * time.Minute
+	// votes and voting window in days for guilds without proposal settings
+	defaultProposalThreshold = 5
+	defaultProposalWindow    = 7
+	minProposalThreshold     = 1.0
+	minProposalWindow        = 1.0
+
+	// less typing by referencing. The Discord client is only known once the configuration is loaded.
+	discordClient *discordgo.Session
 	logger        = c.Configuration.Global.Logger
 
 	// All commands and options must have a description
@@ -73,6 +102,123 @@ var (
 					MaxLength:   maxLength,
 					Required:    true,
 				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "type",
+					Description: "type of the channel to be created, text by default",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "text", Value: "text"},
+						{Name: "voice", Value: "voice"},
+						{Name: "stage", Value: "stage"},
+						{Name: "forum", Value: "forum"},
+					},
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "group",
+					Description:  "channel group the channel belongs to, the default group when left empty",
+					Required:     false,
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "expires",
+					Description: "when the channel expires, a duration such as 36h or 7d or a date such as 2024-05-01 18:00 (UTC)",
+					MaxLength:   maxLength,
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "onexpiry",
+					Description: "what happens to the channel when it expires, delete by default",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "delete", Value: "delete"},
+						{Name: "archive", Value: "archive"},
+					},
+				},
+			},
+		},
+		{
+			Name:         "importchannels",
+			Description:  "Create joinable channels listed in a CSV or YAML file",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+					Name:        "file",
+					Description: "CSV or YAML file with the columns name, topic, type, group, expires, onexpiry, capacity and approval",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:         "adoptchannel",
+			Description:  "Turn an existing channel into a joinable channel, keeping its messages",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionChannel,
+					Name:        "channel",
+					Description: "channel to be adopted",
+					Required:    true,
+					ChannelTypes: []discordgo.ChannelType{
+						discordgo.ChannelTypeGuildText,
+						discordgo.ChannelTypeGuildVoice,
+						discordgo.ChannelTypeGuildStageVoice,
+						discordgo.ChannelTypeGuildForum,
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionRole,
+					Name:        "role",
+					Description: "existing role that gives access to the channel, a new role is created when left empty",
+					Required:    false,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "group",
+					Description:  "channel group the channel belongs to, the default group when left empty",
+					Required:     false,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Name:         "mychannels",
+			Description:  "List the joinable channels you are a member of",
+			DMPermission: &falseBool,
+		},
+		{
+			Name:         "editjoinablechannel",
+			Description:  "Rename a joinable channel or change its topic",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "channelname",
+					Description: "name of the channel to be edited",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "newname",
+					Description: "new name of the channel",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "topic",
+					Description: "new topic of the channel",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    false,
+				},
 			},
 		},
 		{
@@ -90,6 +236,360 @@ var (
 				},
 			},
 		},
+		{
+			Name:         "listjoinablechannels",
+			Description:  "List all joinable channels",
+			DMPermission: &falseBool,
+		},
+		{
+			Name:         "channeldirectory",
+			Description:  "Use a single directory message with select menus instead of a join embed per channel",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "enabled",
+					Description: "enable or disable directory mode",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:         "migratejoinembeds",
+			Description:  "Replace the reactions of old join embeds with buttons",
+			DMPermission: &falseBool,
+		},
+		{
+			Name:         "channelapproval",
+			Description:  "Require moderator approval to join a channel",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "channelname",
+					Description: "name of the channel",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "required",
+					Description: "whether joining the channel requires approval",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:         "channelcapacity",
+			Description:  "Limit the number of members of a channel, further joins go on a waitlist",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "channelname",
+					Description: "name of the channel",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "capacity",
+					Description: "maximum number of members, 0 removes the limit",
+					MinValue:    &minCapacity,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:         "channelprerequisites",
+			Description:  "Set what users need to join a channel, shows the current prerequisites without options",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "channelname",
+					Description: "name of the channel",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "roles",
+					Description: "ids or mentions of the roles users need all of, none removes the requirement",
+					MinLength:   &minLength,
+					MaxLength:   1000,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "accountage",
+					Description: "minimum age of the user's account in days, 0 removes the requirement",
+					MinValue:    &minPrerequisiteDays,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "memberage",
+					Description: "minimum days the user has been a member of this server, 0 removes the requirement",
+					MinValue:    &minPrerequisiteDays,
+				},
+			},
+		},
+		{
+			Name:         "announcements",
+			Description:  "Configure join and leave announcements of the server or of one channel",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "channelname",
+					Description: "joinable channel to override the settings of the server for",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "mode",
+					Description: "where announcements are sent",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "in the channel", Value: "channel"},
+						{Name: "to the log channel", Value: "log"},
+						{Name: "off", Value: "off"},
+						{Name: "default", Value: "default"},
+					},
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					Name:         "logchannel",
+					Description:  "channel announcements are sent to in log mode",
+					ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "clearlogchannel",
+					Description: "remove the log channel, so the one of the server applies again",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "jointemplate",
+					Description: "join announcement with {user}, {username}, {channel} and {members}, default resets it",
+					MinLength:   &minLength,
+					MaxLength:   1000,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "leavetemplate",
+					Description: "leave announcement with {user}, {username}, {channel} and {members}, default resets it",
+					MinLength:   &minLength,
+					MaxLength:   1000,
+				},
+			},
+		},
+		{
+			Name:         "archivechannel",
+			Description:  "Archive a joinable channel",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "channelname",
+					Description: "name of the channel to be archived",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:         "unarchivechannel",
+			Description:  "Restore an archived joinable channel",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "channelname",
+					Description: "name of the channel to be restored",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:         "proposechannel",
+			Description:  "Propose a joinable channel, it is created once enough members vote for it",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "channelname",
+					Description: "name of the proposed channel",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "topic",
+					Description: "topic of the proposed channel",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:         "proposalsettings",
+			Description:  "Configure the votes needed for proposed channels",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "threshold",
+					Description: "votes a proposal needs for the channel to be created",
+					MinValue:    &minProposalThreshold,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "window",
+					Description: "days a proposal is open for votes",
+					MinValue:    &minProposalWindow,
+				},
+			},
+		},
+		{
+			Name:         "archivesettings",
+			Description:  "Configure archiving of inactive joinable channels",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "auto",
+					Description: "automatically archive inactive joinable channels",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "interval",
+					Description: "days without messages after which a channel is archived",
+					MinValue:    &minArchiveInterval,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "archivecategoryid",
+					Description: "id of the category archived channels are moved to",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "warningdays",
+					Description: "days before archiving that a warning is posted in the channel. 0 disables warnings",
+					MinValue:    &minArchiveWarningDays,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "retention",
+					Description: "days archived channels are kept before they are deleted. 0 keeps them forever",
+					MinValue:    &minArchiveRetention,
+				},
+			},
+		},
+		{
+			Name:         "archiveexempt",
+			Description:  "Exempt a joinable channel from auto archiving",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "channelname",
+					Description: "name of the joinable channel",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "exempt",
+					Description: "never archive this channel automatically",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:         "archiveinterval",
+			Description:  "Set a custom inactivity interval for a joinable channel",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "channelname",
+					Description: "name of the joinable channel",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "interval",
+					Description: "days without messages after which the channel is archived. 0 uses the guild interval",
+					MinValue:    &minArchiveOverride,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:         "archivereport",
+			Description:  "Show what the archiver would do with each joinable channel",
+			DMPermission: &falseBool,
+		},
+		{
+			Name:         "creategroup",
+			Description:  "Create a group of joinable channels with its own category and join channel",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "name",
+					Description: "name of the group",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "joinchannelid",
+					Description: "ID of the channel the channels of the group are announced in",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "categoryid",
+					Description: "ID of the category the channels of the group are created in",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:         "deletegroup",
+			Description:  "Delete a group of joinable channels that has no channels left",
+			DMPermission: &falseBool,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "name",
+					Description: "name of the group",
+					MinLength:   &minLength,
+					MaxLength:   maxLength,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:         "repair",
+			Description:  "Check the joinable channels for inconsistencies and repair them",
+			DMPermission: &falseBool,
+		},
 		{
 			Name:         "setup",
 			Description:  "Setup the bot for your guild",
@@ -149,14 +649,60 @@ var (
 
 	commandHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
 		"createjoinablechannel": createJoinableChannel,
+		"importchannels":        importChannels,
+		"adoptchannel":          adoptChannel,
+		"editjoinablechannel":   editJoinableChannel,
 		"deletejoinablechannel": deleteJoinableChannel,
+		"listjoinablechannels":  listJoinableChannels,
+		"mychannels":            myChannels,
+		"migratejoinembeds":     migrateJoinEmbeds,
+		"channeldirectory":      channelDirectoryCommand,
+		"channelapproval":       channelApproval,
+		"channelcapacity":       channelCapacity,
+		"channelprerequisites":  channelPrerequisites,
+		"announcements":         announcementsCommand,
+		"archivechannel":        archiveChannel,
+		"unarchivechannel":      unarchiveChannel,
+		"proposechannel":        proposeChannel,
+		"proposalsettings":      proposalSettingsCommand,
+		"archivesettings":       archiveSettings,
+		"archiveexempt":         archiveExempt,
+		"archiveinterval":       archiveInterval,
+		"archivereport":         archiveReportCommand,
+		"creategroup":           createChannelGroup,
+		"deletegroup":           deleteChannelGroup,
+		"repair":                repairCommand,
 		"setup":                 setupGuild,
 	}
+
+	// Autocompletion is routed on the name of the command
+	autocompleteHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
+		"createjoinablechannel": groupAutocomplete,
+		"adoptchannel":          groupAutocomplete,
+	}
+
+	// Message components are routed on the part of their custom ID before the colon
+	componentHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
+		"joinchannel":    joinChannelButton,
+		"leavechannel":   leaveChannelButton,
+		"directory":      directorySelect,
+		"mydirectory":    personalDirectorySelect,
+		"keepchannel":    keepChannel,
+		"joinrequest":    joinRequestButton,
+		"archivereport":  archiveReportPageButton,
+		"repair":         repairButton,
+		"listjoinable":   listJoinableChannelsPageButton,
+		"proposal":       proposalButton,
+		"importchannels": importChannelsButton,
+		"mychannels":     myChannelsButton,
+	}
 )
 
 func Hirohito(ctx context.Context) {
 	logger.Info("starting emperor hirohito")
 
+	discordClient = c.Configuration.Discord.Client
+
 	hirohitoCtx, hirohitoCancel := context.WithCancel(ctx)
 	defer hirohitoCancel()
 
@@ -165,10 +711,22 @@ func Hirohito(ctx context.Context) {
 		logger.Fatalf("error setting up datastore. Bot cannot function. Error: %s", err)
 	}
 
-	// Register handler for incoming commands
+	// Register handler for incoming commands, autocompletion and message components
 	discordClient.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
-			h(s, i)
+		switch i.Type {
+		case discordgo.InteractionApplicationCommand:
+			if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
+				h(s, i)
+			}
+		case discordgo.InteractionApplicationCommandAutocomplete:
+			if h, ok := autocompleteHandlers[i.ApplicationCommandData().Name]; ok {
+				h(s, i)
+			}
+		case discordgo.InteractionMessageComponent:
+			name, _ := h.ParseComponentID(i.MessageComponentData().CustomID)
+			if h, ok := componentHandlers[name]; ok {
+				h(s, i)
+			}
 		}
 	})
 
@@ -195,12 +753,41 @@ func Hirohito(ctx context.Context) {
 	}
 	defer discordClient.Close()
 
+	backfillAllJoinableChannels()
+	checkAllConsistency()
+
+	var workers sync.WaitGroup
+
+	startWorker(hirohitoCtx, &workers, worker{
+		name:     "archiving",
+		interval: archiveCheckInterval,
+		clock:    time.Now,
+		task:     archivingTask,
+	})
+
+	startWorker(hirohitoCtx, &workers, worker{
+		name:     "expiry",
+		interval: expiryCheckInterval,
+		clock:    time.Now,
+		task:     expiryTask,
+	})
+
+	startWorker(hirohitoCtx, &workers, worker{
+		name:     "proposals",
+		interval: proposalCheckInterval,
+		clock:    time.Now,
+		task:     proposalTask,
+	})
+
 	started = true
 	logger.Infoln("Bot is now running. Press CTRL-C to exit.")
 
 	// wait for the context to report done and then do a cleanup
 	<-ctx.Done()
 
+	hirohitoCancel()
+	workers.Wait()
+
 	/*
 		We need to fetch the commands, since deleting requires the command ID.
 		We are doing this from the returned commands on line 70, because using
@@ -0,0 +1,523 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// largest import file that is downloaded
+	maxImportSize = 1 << 20
+	maxImportRows = 100
+)
+
+// importColumns are the columns of an import file, name and topic are required
+var importColumns = map[string]bool{
+	"name":     true,
+	"topic":    true,
+	"type":     true,
+	"group":    true,
+	"expires":  true,
+	"onexpiry": true,
+	"capacity": true,
+	"approval": true,
+}
+
+// importRow is a validated row of an import file
+type importRow struct {
+	spec    joinableChannelSpec
+	expires string // the expiry is computed again when the channel is created
+}
+
+var importClient = http.Client{Timeout: 30 * time.Second}
+
+// downloadImportFile retrieves an attachment holding channels to import
+func downloadImportFile(attachment *discordgo.MessageAttachment) ([]byte, error) {
+	if attachment.Size > maxImportSize {
+		return nil, fmt.Errorf("the file is too large, it can be at most %d KB", maxImportSize>>10)
+	}
+
+	response, err := importClient.Get(attachment.URL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %s", attachment.Filename, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download %s: %s", attachment.Filename, response.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(response.Body, maxImportSize))
+}
+
+// parseImportFile reads the rows of a CSV file with a header row, or of a YAML list of channels.
+// Every row maps the lowercase column names to their values.
+func parseImportFile(filename string, data []byte) ([]map[string]string, error) {
+	var rows []map[string]string
+
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CSV: %s", err)
+		}
+
+		if len(records) < 1 {
+			return nil, errors.New("the file is empty")
+		}
+
+		header := records[0]
+		for i := range header {
+			header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		}
+
+		for _, record := range records[1:] {
+			row := make(map[string]string)
+			for i, value := range record {
+				row[header[i]] = strings.TrimSpace(value)
+			}
+			rows = append(rows, row)
+		}
+	case ".yaml", ".yml":
+		var entries []map[string]interface{}
+
+		err := yaml.Unmarshal(data, &entries)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read YAML, it has to be a list of channels: %s", err)
+		}
+
+		for _, entry := range entries {
+			row := make(map[string]string)
+			for key, value := range entry {
+				if value != nil {
+					row[strings.ToLower(key)] = strings.TrimSpace(fmt.Sprint(value))
+				}
+			}
+			rows = append(rows, row)
+		}
+	default:
+		return nil, errors.New("unknown file type, attach a .csv, .yaml or .yml file")
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("the file lists no channels")
+	}
+
+	if len(rows) > maxImportRows {
+		return nil, fmt.Errorf("the file lists %d channels, at most %d can be imported at once", len(rows), maxImportRows)
+	}
+
+	return rows, nil
+}
+
+// parseImportBool reads a yes or no column. YAML 1.2 leaves yes and no as strings, so they are accepted
+// next to the values strconv.ParseBool knows.
+func parseImportBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "y", "on":
+		return true, nil
+	case "no", "n", "off":
+		return false, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
+// validateImportRow turns a row of an import file into the spec of a channel
+func validateImportRow(guildInfo *m.GuildInformation, now time.Time, row map[string]string) (*importRow, error) {
+	for column := range row {
+		if !importColumns[column] {
+			return nil, fmt.Errorf("unknown column %s", column)
+		}
+	}
+
+	name := channelName(row["name"])
+	topic := row["topic"]
+
+	if len(name) < minLength || len(name) > maxLength || len(topic) < minLength || len(topic) > maxLength {
+		return nil, errors.New("name and topic need to be between 2 and 100 characters")
+	}
+
+	result := importRow{
+		spec: joinableChannelSpec{
+			name:        name,
+			topic:       topic,
+			channelType: discordgo.ChannelTypeGuildText,
+			groupName:   strings.ToLower(row["group"]),
+		},
+		expires: row["expires"],
+	}
+
+	if value := row["type"]; value != "" {
+		channelType, found := channelTypeOptions[strings.ToLower(value)]
+		if !found {
+			return nil, fmt.Errorf("unknown channel type %s", value)
+		}
+		result.spec.channelType = channelType
+	}
+
+	if _, err := channelGroup(guildInfo, result.spec.groupName); err != nil {
+		return nil, err
+	}
+
+	if result.expires != "" {
+		expiresAt, err := parseExpiry(now, result.expires)
+		if err != nil {
+			return nil, err
+		}
+		result.spec.expiresAt = expiresAt
+	}
+
+	switch strings.ToLower(row["onexpiry"]) {
+	case "", "delete":
+	case "archive":
+		result.spec.expiryArchive = 1
+	default:
+		return nil, fmt.Errorf("onexpiry has to be delete or archive, not %s", row["onexpiry"])
+	}
+
+	if value := row["capacity"]; value != "" {
+		capacity, err := strconv.Atoi(value)
+		if err != nil || capacity < 0 {
+			return nil, fmt.Errorf("capacity has to be a number of 0 or more, not %s", value)
+		}
+		result.spec.capacity = capacity
+	}
+
+	if value := row["approval"]; value != "" {
+		approval, err := parseImportBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("approval has to be yes or no, not %s", value)
+		}
+		if approval {
+			result.spec.requiresApproval = 1
+		}
+	}
+
+	return &result, nil
+}
+
+// validateImport validates every row of an import file up front. It returns the rows,
+// or a description of every row that can not be imported.
+func validateImport(guildInfo *m.GuildInformation, filename string, data []byte) ([]importRow, []string, error) {
+	var rows []importRow
+	var problems []string
+
+	records, err := parseImportFile(filename, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	guildChannels, err := discordClient.GuildChannels(guildInfo.GuildID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve list of guild channels to check uniqueness: %s", err)
+	}
+
+	names := make(map[string]int)
+	now := time.Now()
+
+	for n, record := range records {
+		row, err := validateImportRow(guildInfo, now, record)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("row %d: %s", n+1, err))
+			continue
+		}
+
+		if _, found := h.FindChannel(guildChannels, row.spec.name); found {
+			problems = append(problems, fmt.Sprintf("row %d: a channel named %s already exists", n+1, row.spec.name))
+			continue
+		}
+
+		if first, found := names[row.spec.name]; found {
+			problems = append(problems, fmt.Sprintf("row %d: %s is already imported by row %d", n+1, row.spec.name, first))
+			continue
+		}
+
+		names[row.spec.name] = n + 1
+		rows = append(rows, *row)
+	}
+
+	return rows, problems, nil
+}
+
+func importRowSummary(row importRow) string {
+	var details []string
+
+	for option, channelType := range channelTypeOptions {
+		if channelType == row.spec.channelType {
+			details = append(details, option)
+		}
+	}
+
+	if row.spec.groupName != "" {
+		details = append(details, fmt.Sprintf("group %s", row.spec.groupName))
+	}
+
+	if row.spec.capacity > 0 {
+		details = append(details, fmt.Sprintf("capacity %d", row.spec.capacity))
+	}
+
+	if row.spec.requiresApproval == 1 {
+		details = append(details, "approval")
+	}
+
+	if row.expires != "" {
+		action := "deleted"
+		if row.spec.expiryArchive == 1 {
+			action = "archived"
+		}
+		details = append(details, fmt.Sprintf("%s after %s", action, row.expires))
+	}
+
+	return fmt.Sprintf("#%s (%s): %s", row.spec.name, strings.Join(details, ", "), row.spec.topic)
+}
+
+// importReport numbers the lines of a report up to the given length, 0 lists all lines
+func importReport(lines []string, length int) string {
+	var report strings.Builder
+
+	for i, line := range lines {
+		line = fmt.Sprintf("%d. %s\n", i+1, line)
+		more := fmt.Sprintf("… and %d more", len(lines)-i)
+
+		if length > 0 && report.Len()+len(line)+len(more) > length {
+			report.WriteString(more)
+			break
+		}
+
+		report.WriteString(line)
+	}
+
+	return report.String()
+}
+
+func importChannels(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var attachment *discordgo.MessageAttachment
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	data := i.ApplicationCommandData()
+
+	for _, option := range data.Options {
+		switch option.Name {
+		case "file":
+			attachment = data.Resolved.Attachments[option.Value.(string)]
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	if attachment == nil {
+		h.SendInteractionResponse(s, i, "attach a file listing the channels to import.")
+		return
+	}
+
+	// downloading and validating the file takes a while
+	err = h.SendInteractionAwaitResponse(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	download, err := downloadImportFile(attachment)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	rows, problems, err := validateImport(guildInfo, attachment.Filename, download)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	if len(problems) > 0 {
+		embed := discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("%d rows can not be imported, nothing was created", len(problems)),
+			Type:        discordgo.EmbedTypeRich,
+			Description: importReport(problems, 4000),
+		}
+
+		h.EditInteractionResponseComplex(s, i, []*discordgo.MessageEmbed{&embed}, []discordgo.MessageComponent{})
+		return
+	}
+
+	summaries := make([]string, len(rows))
+	for n, row := range rows {
+		summaries[n] = importRowSummary(row)
+	}
+
+	embed := discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Import %d channels?", len(rows)),
+		Type:        discordgo.EmbedTypeRich,
+		Description: importReport(summaries, 4000),
+	}
+
+	// the file is attached to the preview, so the confirm button can read it again
+	files := []*discordgo.File{
+		{
+			Name:        attachment.Filename,
+			ContentType: attachment.ContentType,
+			Reader:      bytes.NewReader(download),
+		},
+	}
+
+	err = h.EditInteractionResponseFiles(s, i, []*discordgo.MessageEmbed{&embed}, []discordgo.MessageComponent{h.ConfirmButtons("importchannels", "Import")}, files)
+	if err != nil {
+		logger.Errorf("unable to send import preview to guild: %s", err)
+	}
+}
+
+func importChannelsButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionEphemeralResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	err = h.SendInteractionAwaitUpdate(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	embed := i.Message.Embeds[0]
+
+	_, choice := h.ParseComponentID(i.MessageComponentData().CustomID)
+	if choice != "confirm" || len(i.Message.Attachments) < 1 {
+		embed.Description = "Import cancelled."
+		h.EditInteractionResponseComplex(s, i, []*discordgo.MessageEmbed{embed}, []discordgo.MessageComponent{})
+		return
+	}
+
+	// the rows are validated again, channels might have been created since the preview
+	attachment := i.Message.Attachments[0]
+
+	download, err := downloadImportFile(attachment)
+	if err != nil {
+		embed.Description = err.Error()
+		h.EditInteractionResponseComplex(s, i, []*discordgo.MessageEmbed{embed}, []discordgo.MessageComponent{})
+		return
+	}
+
+	rows, problems, err := validateImport(guildInfo, attachment.Filename, download)
+	if err == nil && len(problems) > 0 {
+		err = fmt.Errorf("%d rows can no longer be imported, nothing was created:\n%s", len(problems), importReport(problems, 3900))
+	}
+	if err != nil {
+		embed.Description = err.Error()
+		h.EditInteractionResponseComplex(s, i, []*discordgo.MessageEmbed{embed}, []discordgo.MessageComponent{})
+		return
+	}
+
+	var created int
+	results := make([]string, len(rows))
+
+	for n, row := range rows {
+		embed.Description = fmt.Sprintf("Creating channel %d of %d: #%s", n+1, len(rows), row.spec.name)
+
+		err = h.EditInteractionResponseComplex(s, i, []*discordgo.MessageEmbed{embed}, []discordgo.MessageComponent{})
+		if err != nil {
+			logger.Warnf("unable to send import progress to guild: %s", err)
+		}
+
+		channel, err := createImportRow(guildInfo, row, i.Member.User.ID)
+		if err != nil {
+			results[n] = fmt.Sprintf("#%s: failed, %s", row.spec.name, err)
+			continue
+		}
+
+		results[n] = fmt.Sprintf("#%s: created", channel.Name)
+		created++
+	}
+
+	embed.Title = fmt.Sprintf("Imported %d of %d channels", created, len(rows))
+	embed.Description = importReport(results, 4000)
+
+	err = h.EditInteractionResponseComplex(s, i, []*discordgo.MessageEmbed{embed}, []discordgo.MessageComponent{})
+	if err != nil {
+		logger.Errorf("unable to send import report to guild: %s", err)
+	}
+
+	files := []*discordgo.File{
+		{
+			Name:        "import-report.txt",
+			ContentType: "text/plain",
+			Reader:      strings.NewReader(importReport(results, 0)),
+		},
+	}
+
+	err = c.Messages.SendFiles(guildInfo.AdminChannelID, fmt.Sprintf("Import report: %d of %d channels created", created, len(rows)), files)
+	if err != nil {
+		logger.Errorf("unable to post import report to guild %s: %s", guildInfo.GuildID, err)
+	}
+}
+
+// createImportRow creates the channel of a validated row the way createjoinablechannel does
+func createImportRow(guildInfo *m.GuildInformation, row importRow, creatorID string) (*discordgo.Channel, error) {
+	row.spec.creatorID = creatorID
+
+	if row.expires != "" {
+		expiresAt, err := parseExpiry(time.Now(), row.expires)
+		if err != nil {
+			return nil, err
+		}
+		row.spec.expiresAt = expiresAt
+	}
+
+	channel, _, err := newJoinableChannel(guildInfo, row.spec)
+
+	return channel, err
+}
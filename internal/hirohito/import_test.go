@@ -0,0 +1,240 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	c "hirohito/internal/config"
+	m "hirohito/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseImportFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		want     []map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "csv",
+			filename: "channels.csv",
+			data:     "Name, Topic ,capacity\nboard games, Tabletop evenings ,10\nchess,Checkmate,\n",
+			want: []map[string]string{
+				{"name": "board games", "topic": "Tabletop evenings", "capacity": "10"},
+				{"name": "chess", "topic": "Checkmate", "capacity": ""},
+			},
+		},
+		{
+			name:     "yaml",
+			filename: "channels.YML",
+			data:     "- name: chess\n  topic: Checkmate\n  capacity: 10\n  approval: yes\n- Name: go\n  topic: Baduk\n  group:\n",
+			want: []map[string]string{
+				{"name": "chess", "topic": "Checkmate", "capacity": "10", "approval": "yes"},
+				{"name": "go", "topic": "Baduk"},
+			},
+		},
+		{
+			name:     "yaml that is not a list",
+			filename: "channels.yaml",
+			data:     "name: chess\ntopic: Checkmate\n",
+			wantErr:  true,
+		},
+		{
+			name:     "header only",
+			filename: "channels.csv",
+			data:     "name,topic\n",
+			wantErr:  true,
+		},
+		{
+			name:     "empty file",
+			filename: "channels.csv",
+			data:     "",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown file type",
+			filename: "channels.txt",
+			data:     "name,topic\nchess,Checkmate\n",
+			wantErr:  true,
+		},
+		{
+			name:     "too many rows",
+			filename: "channels.csv",
+			data:     "name,topic\n" + strings.Repeat("chess,Checkmate\n", maxImportRows+1),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseImportFile(tt.filename, []byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseImportFile() = %v, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseImportFile() returned error: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseImportFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateImportRow(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	guildInfo := &m.GuildInformation{GuildID: "import-test", JoinChannelID: "join"}
+
+	err := c.DataStore.CreateChannelGroup(m.ChannelGroup{GuildID: guildInfo.GuildID, Name: "games"})
+	if err != nil {
+		t.Fatalf("unable to create channel group: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		row     map[string]string
+		want    joinableChannelSpec
+		wantErr bool
+	}{
+		{
+			name: "name and topic only",
+			row:  map[string]string{"name": "Board Games", "topic": "Tabletop evenings"},
+			want: joinableChannelSpec{name: "board-games", topic: "Tabletop evenings", channelType: discordgo.ChannelTypeGuildText},
+		},
+		{
+			name: "every column",
+			row: map[string]string{
+				"name": "chess", "topic": "Checkmate", "type": "Voice", "group": "Games",
+				"expires": "7d", "onexpiry": "archive", "capacity": "10", "approval": "yes",
+			},
+			want: joinableChannelSpec{
+				name: "chess", topic: "Checkmate", channelType: discordgo.ChannelTypeGuildVoice, groupName: "games",
+				expiresAt: now.Add(7 * 24 * time.Hour), expiryArchive: 1, capacity: 10, requiresApproval: 1,
+			},
+		},
+		{
+			name: "approval as boolean",
+			row:  map[string]string{"name": "chess", "topic": "Checkmate", "approval": "true"},
+			want: joinableChannelSpec{name: "chess", topic: "Checkmate", channelType: discordgo.ChannelTypeGuildText, requiresApproval: 1},
+		},
+		{
+			name: "approval declined",
+			row:  map[string]string{"name": "chess", "topic": "Checkmate", "approval": "No"},
+			want: joinableChannelSpec{name: "chess", topic: "Checkmate", channelType: discordgo.ChannelTypeGuildText},
+		},
+		{
+			name:    "unknown column",
+			row:     map[string]string{"name": "chess", "topic": "Checkmate", "colour": "red"},
+			wantErr: true,
+		},
+		{
+			name:    "missing topic",
+			row:     map[string]string{"name": "chess"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown channel type",
+			row:     map[string]string{"name": "chess", "topic": "Checkmate", "type": "category"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown group",
+			row:     map[string]string{"name": "chess", "topic": "Checkmate", "group": "sports"},
+			wantErr: true,
+		},
+		{
+			name:    "expiry in the past",
+			row:     map[string]string{"name": "chess", "topic": "Checkmate", "expires": "2024-01-01"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown expiry action",
+			row:     map[string]string{"name": "chess", "topic": "Checkmate", "onexpiry": "keep"},
+			wantErr: true,
+		},
+		{
+			name:    "negative capacity",
+			row:     map[string]string{"name": "chess", "topic": "Checkmate", "capacity": "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid approval",
+			row:     map[string]string{"name": "chess", "topic": "Checkmate", "approval": "maybe"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateImportRow(guildInfo, now, tt.row)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("validateImportRow() = %+v, want an error", got.spec)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("validateImportRow() returned error: %s", err)
+			}
+
+			if !reflect.DeepEqual(got.spec, tt.want) {
+				t.Errorf("validateImportRow() = %+v, want %+v", got.spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportReport(t *testing.T) {
+	lines := []string{"#chess", "#go", "#shogi"}
+
+	tests := []struct {
+		name   string
+		length int
+		want   string
+	}{
+		{name: "all lines", length: 0, want: "1. #chess\n2. #go\n3. #shogi\n"},
+		{name: "fits exactly", length: len("1. #chess\n2. #go\n3. #shogi\n") + len("… and 0 more"), want: "1. #chess\n2. #go\n3. #shogi\n"},
+		{name: "truncated", length: 31, want: "1. #chess\n2. #go\n… and 1 more"},
+		{name: "nothing fits", length: 5, want: "… and 3 more"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := importReport(lines, tt.length)
+			if got != tt.want {
+				t.Errorf("importReport() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"sort"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// joinableChannelRecord returns the datastore record linking a joinable channel to its role and embed
+func joinableChannelRecord(channel *discordgo.Channel) (*m.JoinableChannel, error) {
+	joinableChannel, err := c.DataStore.GetJoinableChannel(channel.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s is not a joinable channel", channel.Name)
+		}
+		return nil, err
+	}
+
+	return joinableChannel, nil
+}
+
+// joinableChannelRecords returns the records of all joinable channels of a guild, indexed by channel ID
+func joinableChannelRecords(guildID string) (map[string]m.JoinableChannel, error) {
+	joinableChannels, err := c.DataStore.GetJoinableChannels(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]m.JoinableChannel, len(joinableChannels))
+	for _, joinableChannel := range joinableChannels {
+		records[joinableChannel.ChannelID] = joinableChannel
+	}
+
+	return records, nil
+}
+
+// joinableChannelByName looks up a channel by its current name and returns it with its record
+func joinableChannelByName(s *discordgo.Session, guildID, name string) (*discordgo.Channel, *m.JoinableChannel, error) {
+	channel, err := h.FindChannelInGuild(s, guildID, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	joinableChannel, err := joinableChannelRecord(channel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return channel, joinableChannel, nil
+}
+
+// deleteJoinableChannelRecords removes every datastore row of a joinable channel after it was deleted.
+// Failures are only logged, as the channel itself is gone already.
+func deleteJoinableChannelRecords(channelID, name string) {
+	err := c.DataStore.DeleteJoinableChannel(channelID)
+	if err != nil {
+		logger.Errorf("unable to delete joinable channel record of %s: %s", name, err)
+	}
+
+	err = c.DataStore.DeleteArchivedChannel(channelID)
+	if err != nil {
+		logger.Errorf("unable to delete archived channel record of %s: %s", name, err)
+	}
+
+	err = c.DataStore.DeleteArchiveOverride(channelID)
+	if err != nil {
+		logger.Errorf("unable to delete archive override of channel %s: %s", name, err)
+	}
+
+	err = c.DataStore.DeleteArchiveWarning(channelID)
+	if err != nil {
+		logger.Errorf("unable to delete archive warning of channel %s: %s", name, err)
+	}
+
+	err = c.DataStore.DeleteJoinRequests(channelID)
+	if err != nil {
+		logger.Errorf("unable to delete join requests of channel %s: %s", name, err)
+	}
+
+	err = c.DataStore.DeleteWaitlist(channelID)
+	if err != nil {
+		logger.Errorf("unable to delete waitlist of channel %s: %s", name, err)
+	}
+
+	err = c.DataStore.DeleteJoinPrerequisites(channelID)
+	if err != nil {
+		logger.Errorf("unable to delete join prerequisites of channel %s: %s", name, err)
+	}
+
+	err = c.DataStore.DeleteChannelAnnouncementSettings(channelID)
+	if err != nil {
+		logger.Errorf("unable to delete announcement settings of channel %s: %s", name, err)
+	}
+}
+
+// joinableChannels returns the recorded channels that are in the categories of the channel groups, i.e. not archived
+func joinableChannels(guildInfo *m.GuildInformation) ([]*discordgo.Channel, error) {
+	var joinable []*discordgo.Channel
+
+	records, err := joinableChannelRecords(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := joinableCategories(guildInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	guildChannels, err := discordClient.GuildChannels(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	categoryPositions := make(map[string]int)
+	for _, channel := range guildChannels {
+		if channel.Type == discordgo.ChannelTypeGuildCategory {
+			categoryPositions[channel.ID] = channel.Position
+		}
+	}
+
+	for _, channel := range guildChannels {
+		if _, found := categories[channel.ParentID]; !found {
+			continue
+		}
+
+		if _, found := records[channel.ID]; found {
+			joinable = append(joinable, channel)
+		}
+	}
+
+	sort.SliceStable(joinable, func(i, j int) bool {
+		if joinable[i].ParentID != joinable[j].ParentID {
+			return categoryPositions[joinable[i].ParentID] < categoryPositions[joinable[j].ParentID]
+		}
+		return joinable[i].Position < joinable[j].Position
+	})
+
+	return joinable, nil
+}
+
+// backfillJoinableChannels records the channels of guilds that were set up before joinable channels were
+// kept in the datastore. Like older versions did, roles and embeds are matched on the channel name.
+func backfillJoinableChannels(guildInfo *m.GuildInformation) error {
+	records, err := c.DataStore.GetJoinableChannels(guildInfo.GuildID)
+	if err != nil {
+		return err
+	}
+
+	if len(records) > 0 {
+		return nil
+	}
+
+	channels, err := c.Channels.GetChannelsInCategory(guildInfo.GuildID, guildInfo.JoinableChannelsCategoryID)
+	if err != nil {
+		return err
+	}
+
+	archivingInfo, err := c.DataStore.GetArchivingInfo(guildInfo.GuildID)
+	if err == nil && archivingInfo.ArchivingCategoryID != "" {
+		archived, err := c.Channels.GetChannelsInCategory(guildInfo.GuildID, archivingInfo.ArchivingCategoryID)
+		if err != nil {
+			return err
+		}
+
+		channels = append(channels, archived...)
+	}
+
+	roles, err := c.Roles.RetrieveRoles(guildInfo.GuildID)
+	if err != nil {
+		return err
+	}
+
+	messages, err := c.Messages.GetMessagesInChannel(guildInfo.JoinChannelID)
+	if err != nil {
+		return err
+	}
+
+	for _, channel := range channels {
+		if channel.Type != discordgo.ChannelTypeGuildText {
+			continue
+		}
+
+		pos, found := h.FindChannelRole(roles, channel.Name)
+		if !found {
+			logger.Warnf("not recording channel %s of guild %s, no role with the same name found", channel.Name, guildInfo.GuildID)
+			continue
+		}
+
+		joinableChannel := m.JoinableChannel{
+			GuildID:   guildInfo.GuildID,
+			ChannelID: channel.ID,
+			RoleID:    roles[pos].ID,
+		}
+
+		joinableChannel.CreatedAt, err = discordgo.SnowflakeTimestamp(channel.ID)
+		if err != nil {
+			return err
+		}
+
+		if channel.ParentID == guildInfo.JoinableChannelsCategoryID {
+			if message, err := h.FindChannelEmbedMessage(messages, channel.Name); err == nil {
+				joinableChannel.EmbedMessageID = message.ID
+			}
+		}
+
+		err = c.DataStore.CreateJoinableChannel(joinableChannel)
+		if err != nil {
+			return err
+		}
+
+		logger.Infof("recorded existing joinable channel %s of guild %s", channel.Name, guildInfo.GuildID)
+	}
+
+	return nil
+}
+
+// backfillAllJoinableChannels runs the backfill for every configured guild
+func backfillAllJoinableChannels() {
+	guildInfos, err := c.DataStore.GetAllGuildInfo()
+	if err != nil {
+		logger.Errorf("unable to retrieve guild information for the joinable channel backfill: %s", err)
+		return
+	}
+
+	for i := range guildInfos {
+		err = backfillJoinableChannels(&guildInfos[i])
+		if err != nil {
+			logger.Errorf("unable to backfill joinable channels of guild %s: %s", guildInfos[i].GuildID, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"context"
+	"database/sql"
+	c "hirohito/internal/config"
+	"os"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// TestMain runs the tests against an in-memory datastore and a Discord client that is never connected
+func TestMain(m *testing.M) {
+	client, err := discordgo.New("Bot test")
+	if err != nil {
+		logger.Fatalf("unable to create the test Discord client: %s", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file::memory:?cache=shared")
+	if err != nil {
+		logger.Fatalf("unable to open the test datastore: %s", err)
+	}
+
+	c.Setup(client, db)
+	discordClient = client
+
+	err = c.DataStore.SetupDatastore(context.Background())
+	if err != nil {
+		logger.Fatalf("unable to set up the test datastore: %s", err)
+	}
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,253 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// joinJoinableChannel gives a user the role of a joinable channel. It returns the outcome for the user.
+func joinJoinableChannel(guildInfo *m.GuildInformation, user *discordgo.User, channel *discordgo.Channel) (string, error) {
+	joinable, err := isJoinableCategory(guildInfo, channel.ParentID)
+	if err != nil {
+		return "", err
+	}
+
+	if !joinable {
+		return fmt.Sprintf("%s can not be joined at the moment.", channel.Mention()), nil
+	}
+
+	joinableChannel, err := joinableChannelRecord(channel)
+	if err != nil {
+		return "", err
+	}
+
+	member, err := c.Users.GetGuildMember(guildInfo.GuildID, user.ID)
+	if err != nil {
+		return "", fmt.Errorf("error getting member %s: %s", user.ID, err)
+	}
+
+	if _, found := h.FindRoleID(member.Roles, joinableChannel.RoleID); found {
+		return fmt.Sprintf("You already joined %s.", channel.Mention()), nil
+	}
+
+	unmet, err := checkPrerequisites(channel, member)
+	if err != nil {
+		return "", err
+	}
+
+	if unmet != "" {
+		return unmet, nil
+	}
+
+	if joinableChannel.RequiresApproval == 1 {
+		return requestJoin(guildInfo, user, channel)
+	}
+
+	return admitChannelMember(guildInfo, user, channel, joinableChannel)
+}
+
+// addChannelMember gives a user the role of a joinable channel and announces it
+func addChannelMember(guildInfo *m.GuildInformation, user *discordgo.User, channel *discordgo.Channel, joinableChannel *m.JoinableChannel) error {
+	err := c.Users.AssignUserToRole(guildInfo.GuildID, user.ID, joinableChannel.RoleID)
+	if err != nil {
+		return fmt.Errorf("error assigning role of channel %s to user %s. Error: %s", channel.Name, user.ID, err)
+	}
+
+	announceMembership(guildInfo, user, channel, joinableChannel, true)
+
+	return nil
+}
+
+// leaveJoinableChannel removes the role of a joinable channel from a user. It returns the outcome for the user.
+func leaveJoinableChannel(guildInfo *m.GuildInformation, user *discordgo.User, channel *discordgo.Channel) (string, error) {
+	joinableChannel, err := joinableChannelRecord(channel)
+	if err != nil {
+		return "", err
+	}
+
+	userRoles, err := c.Users.GetUserRoles(guildInfo.GuildID, user.ID)
+	if err != nil {
+		return "", fmt.Errorf("error getting user %s roles: %s", user.ID, err)
+	}
+
+	if _, found := h.FindRoleID(userRoles, joinableChannel.RoleID); !found {
+		waitlisted, err := leaveWaitlist(channel, user)
+		if err != nil {
+			return "", err
+		}
+
+		if waitlisted {
+			return fmt.Sprintf("You left the waitlist of %s.", channel.Mention()), nil
+		}
+
+		return withdrawJoinRequest(guildInfo, user, channel)
+	}
+
+	err = c.Users.RemoveUserFromRole(guildInfo.GuildID, user.ID, joinableChannel.RoleID)
+	if err != nil {
+		return "", fmt.Errorf("error removing role of channel %s from user %s. Error: %s", channel.Name, user.ID, err)
+	}
+
+	announceMembership(guildInfo, user, channel, joinableChannel, false)
+
+	if joinableChannel.Capacity > 0 {
+		promoteWaitlist(guildInfo, channel, joinableChannel)
+
+		err = refreshJoinEmbed(guildInfo, channel, joinableChannel)
+		if err != nil {
+			logger.Warnf("unable to update join embed of channel %s: %s", channel.Name, err)
+		}
+	}
+
+	return fmt.Sprintf("You left %s.", channel.Mention()), nil
+}
+
+// membershipButton runs the shared part of the join and leave buttons
+func membershipButton(s *discordgo.Session, i *discordgo.InteractionCreate, action func(*m.GuildInformation, *discordgo.User, *discordgo.Channel) (string, error)) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	_, channelID := h.ParseComponentID(i.MessageComponentData().CustomID)
+
+	channel, err := s.Channel(channelID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, "This channel no longer exists.")
+		return
+	}
+
+	// counting the members of a channel with a capacity can take longer than Discord waits for a response
+	err = h.SendInteractionAwaitEphemeralResponse(s, i)
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	result, err := action(guildInfo, i.Member.User, channel)
+	if err != nil {
+		logger.Error(err)
+		h.EditInteractionResponse(s, i, "Something went wrong. Please contact an admin.")
+		return
+	}
+
+	err = h.EditInteractionResponse(s, i, result)
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+	}
+}
+
+func joinChannelButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	membershipButton(s, i, joinJoinableChannel)
+}
+
+func leaveChannelButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	membershipButton(s, i, leaveJoinableChannel)
+}
+
+// migrateJoinEmbeds converts the reaction based join embeds of older versions to buttons
+func migrateJoinEmbeds(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var converted, failed int
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	err = h.SendInteractionAwaitResponse(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	messages, err := c.Messages.GetMessagesInChannel(guildInfo.JoinChannelID)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	for _, message := range messages {
+		if message.Author == nil || message.Author.ID != s.State.User.ID || len(message.Components) > 0 {
+			continue
+		}
+
+		channelID, err := h.EmbedChannelID(message)
+		if err != nil {
+			continue
+		}
+
+		channel, err := s.Channel(channelID)
+		if err != nil {
+			logger.Warnf("join embed %s links to unknown channel %s: %s", message.ID, channelID, err)
+			failed++
+			continue
+		}
+
+		joinableChannel, err := joinableChannelRecord(channel)
+		if err != nil {
+			logger.Warnf("join embed %s: %s", message.ID, err)
+			failed++
+			continue
+		}
+
+		members, err := channelMembers(guildInfo, joinableChannel)
+		if err != nil {
+			logger.Error(err)
+			failed++
+			continue
+		}
+
+		err = c.Messages.ConvertJoinableChannelEmbed(message, channel, members, joinableChannel.Capacity)
+		if err != nil {
+			logger.Errorf("unable to convert join embed of channel %s: %s", channel.Name, err)
+			failed++
+			continue
+		}
+
+		if joinableChannel.EmbedMessageID != message.ID {
+			joinableChannel.EmbedMessageID = message.ID
+
+			err = c.DataStore.CreateJoinableChannel(*joinableChannel)
+			if err != nil {
+				logger.Errorf("unable to update join embed of channel %s: %s", channel.Name, err)
+			}
+		}
+
+		converted++
+	}
+
+	h.EditInteractionResponse(s, i, fmt.Sprintf("Converted %d join embeds to buttons. %d embeds could not be converted.", converted, failed))
+}
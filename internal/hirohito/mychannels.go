@@ -0,0 +1,257 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const myChannelsPageSize = 10
+
+// memberChannels returns the joinable channels a user is a member of, sorted by name
+func memberChannels(guildInfo *m.GuildInformation, userID string) ([]*discordgo.Channel, error) {
+	var channels []*discordgo.Channel
+
+	userRoles, err := c.Users.GetUserRoles(guildInfo.GuildID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user %s roles: %s", userID, err)
+	}
+
+	records, err := joinableChannelRecords(guildInfo.GuildID)
+	if err != nil {
+		return nil, err
+	}
+
+	guildChannels, err := discordClient.GuildChannels(guildInfo.GuildID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve guild channels: %s", err)
+	}
+
+	for _, channel := range guildChannels {
+		record, found := records[channel.ID]
+		if !found {
+			continue
+		}
+
+		if _, member := h.FindRoleID(userRoles, record.RoleID); member {
+			channels = append(channels, channel)
+		}
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Name < channels[j].Name
+	})
+
+	return channels, nil
+}
+
+// myChannelsPage lists a page of the channels of a user with a leave button for each of them.
+// The notice reports the outcome of the previous action above the list.
+func myChannelsPage(guildInfo *m.GuildInformation, user *discordgo.User, page int, notice string) ([]*discordgo.MessageEmbed, []discordgo.MessageComponent, error) {
+	channels, err := memberChannels(guildInfo, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start, end, page, pages := h.Paginate(len(channels), myChannelsPageSize, page)
+
+	embed := discordgo.MessageEmbed{
+		Title: fmt.Sprintf("Your joinable channels (page %d/%d)", page+1, pages),
+		Type:  discordgo.EmbedTypeRich,
+	}
+
+	var description strings.Builder
+	if notice != "" {
+		description.WriteString(notice + "\n\n")
+	}
+
+	if len(channels) == 0 {
+		description.WriteString("You have not joined any joinable channels.")
+	}
+
+	components := []discordgo.MessageComponent{}
+	var row discordgo.ActionsRow
+
+	for _, channel := range channels[start:end] {
+		description.WriteString(fmt.Sprintf("• %s\n", channel.Mention()))
+
+		row.Components = append(row.Components, discordgo.Button{
+			Label:    h.Truncate("Leave #"+channel.Name, 80),
+			Style:    discordgo.SecondaryButton,
+			CustomID: h.ComponentID("mychannels", fmt.Sprintf("leave-%s-%d", channel.ID, page)),
+		})
+
+		// an action row holds at most 5 buttons
+		if len(row.Components) == 5 {
+			components = append(components, row)
+			row = discordgo.ActionsRow{}
+		}
+	}
+
+	if len(row.Components) > 0 {
+		components = append(components, row)
+	}
+
+	if pages > 1 {
+		components = append(components, h.PageButtons("mychannels", page, pages))
+	}
+
+	if len(channels) > 0 {
+		components = append(components, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Leave all",
+					Style:    discordgo.DangerButton,
+					CustomID: h.ComponentID("mychannels", "leaveall"),
+				},
+			},
+		})
+	}
+
+	embed.Description = description.String()
+
+	return []*discordgo.MessageEmbed{&embed}, components, nil
+}
+
+// leaveAllChannels removes a user from every joinable channel they are a member of and returns the outcome
+func leaveAllChannels(guildInfo *m.GuildInformation, user *discordgo.User) (string, error) {
+	var failed int
+
+	channels, err := memberChannels(guildInfo, user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, channel := range channels {
+		_, err = leaveJoinableChannel(guildInfo, user, channel)
+		if err != nil {
+			logger.Errorf("unable to remove user %s from channel %s: %s", user.ID, channel.Name, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Sprintf("You left %d channels, %d could not be left. Please contact an admin.", len(channels)-failed, failed), nil
+	}
+
+	return fmt.Sprintf("You left %d channels.", len(channels)), nil
+}
+
+func myChannels(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	err = h.SendInteractionAwaitEphemeralResponse(s, i)
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	embeds, components, err := myChannelsPage(guildInfo, i.Member.User, 0, "")
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	err = h.EditInteractionResponseComplex(s, i, embeds, components)
+	if err != nil {
+		logger.Errorf("unable to send channel list to guild: %s", err)
+	}
+}
+
+func myChannelsButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var page int
+	var notice string
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	err = h.SendInteractionAwaitUpdate(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	_, value := h.ParseComponentID(i.MessageComponentData().CustomID)
+
+	switch {
+	case value == "leaveall":
+		embed := discordgo.MessageEmbed{
+			Title:       "Leave all your joinable channels?",
+			Type:        discordgo.EmbedTypeRich,
+			Description: "You lose access to all of them, you can join them again later.",
+		}
+
+		err = h.EditInteractionResponseComplex(s, i, []*discordgo.MessageEmbed{&embed}, []discordgo.MessageComponent{h.ConfirmButtons("mychannels", "Leave all")})
+		if err != nil {
+			logger.Errorf("unable to send confirmation to guild: %s", err)
+		}
+		return
+	case value == "confirm":
+		notice, err = leaveAllChannels(guildInfo, i.Member.User)
+	case value == "cancel":
+		notice = "You stayed in your channels."
+	case strings.HasPrefix(value, "leave-"):
+		parts := strings.Split(strings.TrimPrefix(value, "leave-"), "-")
+		page, _ = strconv.Atoi(parts[len(parts)-1])
+
+		channel, channelErr := s.Channel(parts[0])
+		if channelErr != nil {
+			notice = "This channel no longer exists."
+			break
+		}
+
+		notice, err = leaveJoinableChannel(guildInfo, i.Member.User, channel)
+	default:
+		page, _ = strconv.Atoi(value)
+	}
+
+	if err != nil {
+		logger.Error(err)
+		notice = "Something went wrong. Please contact an admin."
+	}
+
+	embeds, components, err := myChannelsPage(guildInfo, i.Member.User, page, notice)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	err = h.EditInteractionResponseComplex(s, i, embeds, components)
+	if err != nil {
+		logger.Errorf("unable to send channel list to guild: %s", err)
+	}
+}
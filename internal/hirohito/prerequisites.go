@@ -0,0 +1,220 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// joinPrerequisites returns the prerequisites of a channel, or nil when anyone can join it
+func joinPrerequisites(channelID string) (*m.JoinPrerequisites, error) {
+	prerequisites, err := c.DataStore.GetJoinPrerequisites(channelID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return prerequisites, nil
+}
+
+// unmetPrerequisites describes every prerequisite of a channel the member does not meet
+func unmetPrerequisites(now time.Time, prerequisites *m.JoinPrerequisites, member *discordgo.Member) ([]string, error) {
+	var unmet []string
+
+	for _, roleID := range prerequisites.RequiredRoleIDs {
+		if _, found := h.FindRoleID(member.Roles, roleID); !found {
+			unmet = append(unmet, fmt.Sprintf("you need the <@&%s> role", roleID))
+		}
+	}
+
+	if prerequisites.MinAccountAge > 0 {
+		createdAt, err := discordgo.SnowflakeTimestamp(member.User.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if now.Sub(createdAt) < days(prerequisites.MinAccountAge) {
+			unmet = append(unmet, fmt.Sprintf("your account has to be at least %d days old", prerequisites.MinAccountAge))
+		}
+	}
+
+	if prerequisites.MinMemberAge > 0 && now.Sub(member.JoinedAt) < days(prerequisites.MinMemberAge) {
+		unmet = append(unmet, fmt.Sprintf("you have to be a member of this server for at least %d days", prerequisites.MinMemberAge))
+	}
+
+	return unmet, nil
+}
+
+// checkPrerequisites returns why a member can not join a channel yet, or an empty string when they can
+func checkPrerequisites(channel *discordgo.Channel, member *discordgo.Member) (string, error) {
+	prerequisites, err := joinPrerequisites(channel.ID)
+	if err != nil || prerequisites == nil {
+		return "", err
+	}
+
+	unmet, err := unmetPrerequisites(time.Now(), prerequisites, member)
+	if err != nil || len(unmet) == 0 {
+		return "", err
+	}
+
+	return fmt.Sprintf("You can not join %s yet:\n- %s", channel.Mention(), strings.Join(unmet, "\n- ")), nil
+}
+
+// parseRoleIDs extracts role IDs from a list of role IDs or role mentions separated by spaces or commas
+func parseRoleIDs(value string) []string {
+	var roleIDs []string
+
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	for _, field := range fields {
+		roleID := strings.TrimSuffix(strings.TrimPrefix(field, "<@&"), ">")
+		if _, found := h.FindRoleID(roleIDs, roleID); !found {
+			roleIDs = append(roleIDs, roleID)
+		}
+	}
+
+	return roleIDs
+}
+
+func prerequisitesSummary(prerequisites *m.JoinPrerequisites) string {
+	roles := "none"
+	if len(prerequisites.RequiredRoleIDs) > 0 {
+		roles = fmt.Sprintf("<@&%s>", strings.Join(prerequisites.RequiredRoleIDs, ">, <@&"))
+	}
+
+	return fmt.Sprintf("required roles: %s\nminimum account age: %d days\nminimum membership: %d days", roles, prerequisites.MinAccountAge, prerequisites.MinMemberAge)
+}
+
+func channelPrerequisites(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name string
+	var changed bool
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "channelname" {
+			name = option.StringValue()
+		}
+	}
+
+	channel, _, err := joinableChannelByName(s, i.GuildID, name)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	prerequisites, err := joinPrerequisites(channel.ID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	if prerequisites == nil {
+		prerequisites = &m.JoinPrerequisites{
+			GuildID:   i.GuildID,
+			ChannelID: channel.ID,
+		}
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "channelname":
+			continue
+		case "roles":
+			prerequisites.RequiredRoleIDs = nil
+			if !strings.EqualFold(option.StringValue(), "none") {
+				prerequisites.RequiredRoleIDs = parseRoleIDs(option.StringValue())
+			}
+		case "accountage":
+			prerequisites.MinAccountAge = int(option.IntValue())
+		case "memberage":
+			prerequisites.MinMemberAge = int(option.IntValue())
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+		changed = true
+	}
+
+	if !changed {
+		h.SendInteractionResponse(s, i, fmt.Sprintf("Prerequisites of %s:\n%s", channel.Mention(), prerequisitesSummary(prerequisites)))
+		return
+	}
+
+	if len(prerequisites.RequiredRoleIDs) > 0 {
+		guildRoles, err := s.GuildRoles(i.GuildID)
+		if err != nil {
+			h.SendInteractionResponse(s, i, fmt.Sprintf("unable to retrieve roles to check the required roles: %s", err))
+			return
+		}
+
+		for _, roleID := range prerequisites.RequiredRoleIDs {
+			found := false
+			for _, role := range guildRoles {
+				if role.ID == roleID {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				h.SendInteractionResponse(s, i, fmt.Sprintf("%s is not a role of this server.", roleID))
+				return
+			}
+		}
+	}
+
+	if len(prerequisites.RequiredRoleIDs) == 0 && prerequisites.MinAccountAge == 0 && prerequisites.MinMemberAge == 0 {
+		err = c.DataStore.DeleteJoinPrerequisites(channel.ID)
+	} else {
+		err = c.DataStore.CreateJoinPrerequisites(*prerequisites)
+	}
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	h.SendInteractionResponse(s, i, fmt.Sprintf("Prerequisites of %s saved.\n%s", channel.Mention(), prerequisitesSummary(prerequisites)))
+}
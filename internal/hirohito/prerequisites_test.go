@@ -0,0 +1,100 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	m "hirohito/internal/models"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// snowflake returns a Discord ID that was created at the given time
+func snowflake(createdAt time.Time) string {
+	const discordEpoch = 1420070400000
+
+	return strconv.FormatInt((createdAt.UnixMilli()-discordEpoch)<<22, 10)
+}
+
+func TestUnmetPrerequisites(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	member := &discordgo.Member{
+		User:     &discordgo.User{ID: snowflake(now.Add(-days(10)))},
+		JoinedAt: now.Add(-days(3)),
+		Roles:    []string{"verified"},
+	}
+
+	tests := []struct {
+		name          string
+		prerequisites m.JoinPrerequisites
+		want          []string
+	}{
+		{
+			name: "no prerequisites",
+		},
+		{
+			name:          "all met",
+			prerequisites: m.JoinPrerequisites{RequiredRoleIDs: []string{"verified"}, MinAccountAge: 10, MinMemberAge: 3},
+		},
+		{
+			name:          "missing roles",
+			prerequisites: m.JoinPrerequisites{RequiredRoleIDs: []string{"verified", "adult", "regular"}},
+			want:          []string{"you need the <@&adult> role", "you need the <@&regular> role"},
+		},
+		{
+			name:          "account too young",
+			prerequisites: m.JoinPrerequisites{MinAccountAge: 11},
+			want:          []string{"your account has to be at least 11 days old"},
+		},
+		{
+			name:          "member too recently",
+			prerequisites: m.JoinPrerequisites{MinMemberAge: 4},
+			want:          []string{"you have to be a member of this server for at least 4 days"},
+		},
+		{
+			name:          "everything unmet",
+			prerequisites: m.JoinPrerequisites{RequiredRoleIDs: []string{"adult"}, MinAccountAge: 30, MinMemberAge: 30},
+			want: []string{
+				"you need the <@&adult> role",
+				"your account has to be at least 30 days old",
+				"you have to be a member of this server for at least 30 days",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unmetPrerequisites(now, &tt.prerequisites, member)
+			if err != nil {
+				t.Fatalf("unmetPrerequisites() returned error: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unmetPrerequisites() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
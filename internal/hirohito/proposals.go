@@ -0,0 +1,418 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// proposalLock serializes votes, vetoes and the closing of proposals so a proposal is only accepted once
+var proposalLock sync.Mutex
+
+// proposalSettings returns the proposal settings of a guild, falling back on the defaults
+func proposalSettings(guildID string) (*m.ProposalSettings, error) {
+	settings, err := c.DataStore.GetProposalSettings(guildID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		settings = &m.ProposalSettings{
+			GuildID:   guildID,
+			Threshold: defaultProposalThreshold,
+			Window:    defaultProposalWindow,
+		}
+	}
+
+	return settings, nil
+}
+
+func proposalClosesAt(proposal *m.ChannelProposal, settings *m.ProposalSettings) time.Time {
+	return proposal.ProposedAt.Add(days(settings.Window))
+}
+
+func proposeChannel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name, topic string
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "channelname":
+			name = channelName(option.StringValue())
+		case "topic":
+			topic = option.StringValue()
+		default:
+			h.SendInteractionEphemeralResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	if name == "" || topic == "" {
+		h.SendInteractionEphemeralResponse(s, i, "name or topic are empty. Both need to be between 2 and 100 characters.")
+		return
+	}
+
+	guildChannels, err := s.GuildChannels(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, fmt.Sprintf("Unable to retrieve list of guild channels to check uniqueness: %s", err))
+		return
+	}
+
+	if _, found := h.FindChannel(guildChannels, name); found {
+		h.SendInteractionEphemeralResponse(s, i, "Requested channel name already exists.")
+		return
+	}
+
+	proposals, err := c.DataStore.GetChannelProposals(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	for _, proposal := range proposals {
+		if proposal.Name == name {
+			h.SendInteractionEphemeralResponse(s, i, "A channel with this name was already proposed, vote for that proposal instead.")
+			return
+		}
+	}
+
+	settings, err := proposalSettings(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	now := time.Now()
+	closesAt := now.Add(days(settings.Window))
+
+	message, err := c.Messages.ChannelProposalMessage(guildInfo.JoinChannelID, name, topic, i.Member.User.ID, settings.Threshold, closesAt)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, fmt.Sprintf("unable to post proposal: %s", err))
+		return
+	}
+
+	adminMessage, err := c.Messages.ProposalVetoMessage(guildInfo.AdminChannelID, message, i.GuildID, name, topic, i.Member.User.ID)
+	if err != nil {
+		c.Messages.DeleteMessage(message.ChannelID, message.ID)
+		h.SendInteractionEphemeralResponse(s, i, fmt.Sprintf("unable to post proposal: %s", err))
+		return
+	}
+
+	err = c.DataStore.CreateChannelProposal(m.ChannelProposal{
+		GuildID:        i.GuildID,
+		ChannelID:      message.ChannelID,
+		MessageID:      message.ID,
+		AdminMessageID: adminMessage.ID,
+		Name:           name,
+		Topic:          topic,
+		ProposerID:     i.Member.User.ID,
+		ProposedAt:     now,
+	})
+	if err != nil {
+		c.Messages.DeleteMessage(message.ChannelID, message.ID)
+		c.Messages.DeleteMessage(adminMessage.ChannelID, adminMessage.ID)
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	h.SendInteractionEphemeralResponse(s, i, fmt.Sprintf("Your proposal was posted in <#%s>. It needs %d votes <t:%d:R> for the channel to be created.", guildInfo.JoinChannelID, settings.Threshold, closesAt.Unix()))
+}
+
+// closeChannelProposal removes a proposal and records its outcome in its messages
+func closeChannelProposal(proposal *m.ChannelProposal, adminChannelID, outcome string) {
+	err := c.DataStore.DeleteChannelProposal(proposal.MessageID)
+	if err != nil {
+		logger.Errorf("unable to delete proposal for channel %s: %s", proposal.Name, err)
+	}
+
+	err = c.Messages.CloseChannelProposal(proposal.ChannelID, proposal.MessageID, outcome)
+	if err != nil {
+		logger.Warnf("unable to close proposal message for channel %s: %s", proposal.Name, err)
+	}
+
+	err = c.Messages.CloseChannelProposal(adminChannelID, proposal.AdminMessageID, outcome)
+	if err != nil {
+		logger.Warnf("unable to close proposal message for channel %s in the admin channel: %s", proposal.Name, err)
+	}
+}
+
+// acceptChannelProposal creates a proposed channel the way createjoinablechannel does and adds the proposer to it
+func acceptChannelProposal(guildInfo *m.GuildInformation, proposal *m.ChannelProposal) (*discordgo.Channel, error) {
+	channel, joinableChannel, err := newJoinableChannel(guildInfo, joinableChannelSpec{
+		name:        proposal.Name,
+		topic:       proposal.Topic,
+		channelType: discordgo.ChannelTypeGuildText,
+		creatorID:   proposal.ProposerID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	closeChannelProposal(proposal, guildInfo.AdminChannelID, fmt.Sprintf("Accepted, %s was created.", channel.Mention()))
+
+	proposer, err := discordClient.User(proposal.ProposerID)
+	if err == nil {
+		err = addChannelMember(guildInfo, proposer, channel, joinableChannel)
+	}
+	if err != nil {
+		logger.Warnf("unable to add proposer %s to channel %s: %s", proposal.ProposerID, channel.Name, err)
+	}
+
+	return channel, nil
+}
+
+func proposalButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	_, choice := h.ParseComponentID(i.MessageComponentData().CustomID)
+
+	switch choice {
+	case "upvote":
+		upvoteProposal(s, i, guildInfo)
+	case "veto":
+		vetoProposal(s, i, guildInfo)
+	default:
+		h.SendInteractionEphemeralResponse(s, i, h.UnknownOption)
+	}
+}
+
+// upvoteProposal toggles the vote of a user and creates the channel once the threshold is reached
+func upvoteProposal(s *discordgo.Session, i *discordgo.InteractionCreate, guildInfo *m.GuildInformation) {
+	proposalLock.Lock()
+	defer proposalLock.Unlock()
+
+	proposal, err := c.DataStore.GetChannelProposal(i.Message.ID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, "This proposal is closed.")
+		return
+	}
+
+	settings, err := proposalSettings(i.GuildID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	closesAt := proposalClosesAt(proposal, settings)
+	if !time.Now().Before(closesAt) {
+		closeChannelProposal(proposal, guildInfo.AdminChannelID, fmt.Sprintf("Voting closed without reaching %d votes.", settings.Threshold))
+		h.SendInteractionEphemeralResponse(s, i, "Voting on this proposal has closed.")
+		return
+	}
+
+	votes, err := c.DataStore.GetProposalVotes(proposal.MessageID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	voted := false
+	for _, userID := range votes {
+		if userID == i.Member.User.ID {
+			voted = true
+			break
+		}
+	}
+
+	result := "Your vote was counted."
+
+	if voted {
+		err = c.DataStore.DeleteProposalVote(proposal.MessageID, i.Member.User.ID)
+		result = "You withdrew your vote."
+	} else {
+		err = c.DataStore.CreateProposalVote(proposal.MessageID, i.Member.User.ID)
+	}
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	votes, err = c.DataStore.GetProposalVotes(proposal.MessageID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, err.Error())
+		return
+	}
+
+	if len(votes) < settings.Threshold {
+		err = c.Messages.EditChannelProposalMessage(proposal.ChannelID, proposal.MessageID, proposal.Name, proposal.Topic, proposal.ProposerID, len(votes), settings.Threshold, closesAt)
+		if err != nil {
+			logger.Warnf("unable to update votes on proposal for channel %s: %s", proposal.Name, err)
+		}
+
+		h.SendInteractionEphemeralResponse(s, i, result)
+		return
+	}
+
+	// creating the channel easily takes longer than discord waits for a response
+	err = h.SendInteractionAwaitEphemeralResponse(s, i)
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	channel, err := acceptChannelProposal(guildInfo, proposal)
+	if err != nil {
+		logger.Errorf("unable to create proposed channel %s in guild %s: %s", proposal.Name, guildInfo.GuildID, err)
+
+		err = c.Messages.SendMessage(guildInfo.AdminChannelID, fmt.Sprintf("The proposal for #%s reached %d votes, but the channel could not be created: %s", proposal.Name, len(votes), err))
+		if err != nil {
+			logger.Errorf("unable to notify admins of guild %s: %s", guildInfo.GuildID, err)
+		}
+
+		h.EditInteractionResponse(s, i, "Your vote was counted and the proposal was accepted, but the channel could not be created. The admins were notified.")
+		return
+	}
+
+	h.EditInteractionResponse(s, i, fmt.Sprintf("Your vote was counted and the proposal was accepted: %s", channel.Mention()))
+}
+
+func vetoProposal(s *discordgo.Session, i *discordgo.InteractionCreate, guildInfo *m.GuildInformation) {
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionEphemeralResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	proposalLock.Lock()
+	defer proposalLock.Unlock()
+
+	proposal, err := c.DataStore.GetChannelProposalByAdminMessage(i.Message.ID)
+	if err != nil {
+		h.SendInteractionEphemeralResponse(s, i, "This proposal is closed.")
+		return
+	}
+
+	err = h.SendInteractionAwaitUpdate(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+	}
+
+	closeChannelProposal(proposal, guildInfo.AdminChannelID, fmt.Sprintf("Vetoed by %s.", i.Member.User.Mention()))
+
+	err = c.Messages.SendDirectMessage(proposal.ProposerID, fmt.Sprintf("Your proposal for #%s was vetoed by the admins.", proposal.Name))
+	if err != nil {
+		logger.Warnf("unable to send veto to user %s: %s", proposal.ProposerID, err)
+	}
+}
+
+// proposalTask closes the proposals whose voting window has passed without reaching the threshold
+func proposalTask(now time.Time) {
+	proposals, err := c.DataStore.GetAllChannelProposals()
+	if err != nil {
+		logger.Errorf("unable to retrieve channel proposals: %s", err)
+		return
+	}
+
+	proposalLock.Lock()
+	defer proposalLock.Unlock()
+
+	for i := range proposals {
+		proposal := &proposals[i]
+
+		settings, err := proposalSettings(proposal.GuildID)
+		if err != nil {
+			logger.Errorf("unable to retrieve proposal settings of guild %s: %s", proposal.GuildID, err)
+			continue
+		}
+
+		if now.Before(proposalClosesAt(proposal, settings)) {
+			continue
+		}
+
+		guildInfo, err := checkGuildSetup(proposal.GuildID)
+		if err != nil {
+			logger.Warnf("skipping proposal for channel %s: %s", proposal.Name, err)
+			continue
+		}
+
+		closeChannelProposal(proposal, guildInfo.AdminChannelID, fmt.Sprintf("Voting closed without reaching %d votes.", settings.Threshold))
+
+		logger.Infof("closed expired proposal for channel %s in guild %s", proposal.Name, guildInfo.GuildID)
+	}
+}
+
+func proposalSettingsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	settings, err := proposalSettings(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	if len(i.ApplicationCommandData().Options) < 1 {
+		h.SendInteractionResponse(s, i, proposalSettingsSummary(settings))
+		return
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "threshold":
+			settings.Threshold = int(option.IntValue())
+		case "window":
+			settings.Window = int(option.IntValue())
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	err = c.DataStore.CreateProposalSettings(*settings)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	h.SendInteractionResponse(s, i, fmt.Sprintf("Proposal settings saved.\n%s", proposalSettingsSummary(settings)))
+}
+
+func proposalSettingsSummary(settings *m.ProposalSettings) string {
+	return fmt.Sprintf("votes needed: %d\nvoting window: %d days", settings.Threshold, settings.Window)
+}
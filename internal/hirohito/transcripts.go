@@ -0,0 +1,246 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	c "hirohito/internal/config"
+	m "hirohito/internal/models"
+	t "hirohito/internal/transcripts"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// channelHistory retrieves the messages of a channel. A forum has no messages of its own, so the messages
+// of all of its posts are retrieved instead, together with the names of the posts by their IDs.
+func channelHistory(channel *discordgo.Channel) ([]*discordgo.Message, map[string]string, error) {
+	if channel.Type != discordgo.ChannelTypeGuildForum {
+		messages, err := c.Messages.GetMessagesInChannel(channel.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to retrieve messages of channel %s: %s", channel.Name, err)
+		}
+
+		return messages, nil, nil
+	}
+
+	posts, err := c.Channels.GetForumPosts(channel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve posts of forum %s: %s", channel.Name, err)
+	}
+
+	var messages []*discordgo.Message
+	threads := make(map[string]string, len(posts))
+
+	for _, post := range posts {
+		postMessages, err := c.Messages.GetMessagesInChannel(post.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to retrieve messages of post %s in forum %s: %s", post.Name, channel.Name, err)
+		}
+
+		messages = append(messages, postMessages...)
+		threads[post.ID] = post.Name
+	}
+
+	return messages, threads, nil
+}
+
+// maxTranscriptUpload is the largest combined size of the transcript files posted in a single message,
+// which stays below the upload limit of Discord.
+const maxTranscriptUpload = 8 << 20
+
+// exportTranscript saves the history of a channel as JSON and HTML. The files are written to the
+// configured transcript directory, or posted in the admin channel when no directory is configured.
+// Transcripts too large for a single upload are compressed and split into parts.
+func exportTranscript(guildInfo *m.GuildInformation, channel *discordgo.Channel, reason string) error {
+	messages, threads, err := channelHistory(channel)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	baseName := fmt.Sprintf("%s-%s-%s", channel.Name, channel.ID, now.Format("20060102-150405"))
+
+	path := c.Configuration.Transcripts.Path
+	if path != "" {
+		transcript := t.New(channel, messages, threads, now)
+
+		jsonData, err := transcript.JSON()
+		if err != nil {
+			return err
+		}
+
+		htmlData, err := transcript.HTML()
+		if err != nil {
+			return err
+		}
+
+		dir := filepath.Join(path, guildInfo.GuildID)
+
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, baseName+".json"), jsonData, 0o640); err != nil {
+			return err
+		}
+
+		return os.WriteFile(filepath.Join(dir, baseName+".html"), htmlData, 0o640)
+	}
+
+	// oldest first, so every part of a split transcript covers a continuous stretch of the history
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+
+	parts, err := transcriptParts(channel, messages, threads, now)
+	if err != nil {
+		return err
+	}
+
+	for index, part := range parts {
+		name := baseName
+		content := fmt.Sprintf("Transcript of #%s (%s, %d messages)", channel.Name, reason, len(messages))
+		if len(parts) > 1 {
+			name = fmt.Sprintf("%s-part%d", baseName, index+1)
+			content = fmt.Sprintf("Transcript of #%s (%s, %d messages, part %d of %d)", channel.Name, reason, len(messages), index+1, len(parts))
+		}
+
+		files, _, err := transcriptFiles(channel, part, threads, now, name)
+		if err != nil {
+			return err
+		}
+
+		err = c.Messages.SendFiles(guildInfo.AdminChannelID, content, files)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transcriptParts splits the messages of a channel into runs of consecutive messages whose
+// transcript files fit in a single upload.
+func transcriptParts(channel *discordgo.Channel, messages []*discordgo.Message, threads map[string]string, now time.Time) ([][]*discordgo.Message, error) {
+	_, size, err := transcriptFiles(channel, messages, threads, now, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if size <= maxTranscriptUpload || len(messages) < 2 {
+		return [][]*discordgo.Message{messages}, nil
+	}
+
+	half := len(messages) / 2
+
+	first, err := transcriptParts(channel, messages[:half], threads, now)
+	if err != nil {
+		return nil, err
+	}
+
+	second, err := transcriptParts(channel, messages[half:], threads, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(first, second...), nil
+}
+
+// transcriptFiles renders the JSON and HTML files of a transcript for upload, gzipped when they
+// are too large to upload as is. It also returns the combined size of the files.
+func transcriptFiles(channel *discordgo.Channel, messages []*discordgo.Message, threads map[string]string, now time.Time, name string) ([]*discordgo.File, int, error) {
+	transcript := t.New(channel, messages, threads, now)
+
+	jsonData, err := transcript.JSON()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	htmlData, err := transcript.HTML()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(jsonData)+len(htmlData) <= maxTranscriptUpload {
+		files := []*discordgo.File{
+			{
+				Name:        name + ".json",
+				ContentType: "application/json",
+				Reader:      bytes.NewReader(jsonData),
+			},
+			{
+				Name:        name + ".html",
+				ContentType: "text/html",
+				Reader:      bytes.NewReader(htmlData),
+			},
+		}
+
+		return files, len(jsonData) + len(htmlData), nil
+	}
+
+	jsonData, err = gzipData(jsonData)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	htmlData, err = gzipData(htmlData)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	files := []*discordgo.File{
+		{
+			Name:        name + ".json.gz",
+			ContentType: "application/gzip",
+			Reader:      bytes.NewReader(jsonData),
+		},
+		{
+			Name:        name + ".html.gz",
+			ContentType: "application/gzip",
+			Reader:      bytes.NewReader(htmlData),
+		},
+	}
+
+	return files, len(jsonData) + len(htmlData), nil
+}
+
+func gzipData(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,285 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"fmt"
+	c "hirohito/internal/config"
+	h "hirohito/internal/helpers"
+	m "hirohito/internal/models"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// admissionLocks holds a mutex per channel with a capacity. Admitting a member counts the members and
+// assigns the role under that lock, so users joining at the same time cannot fill the channel past its capacity.
+var admissionLocks sync.Map
+
+func admissionLock(channelID string) *sync.Mutex {
+	lock, _ := admissionLocks.LoadOrStore(channelID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// channelMembers counts the members of a joinable channel with a capacity. Channels without one are not counted.
+func channelMembers(guildInfo *m.GuildInformation, joinableChannel *m.JoinableChannel) (int, error) {
+	if joinableChannel.Capacity <= 0 {
+		return 0, nil
+	}
+
+	counts, err := c.Users.CountRoleMembers(guildInfo.GuildID)
+	if err != nil {
+		return 0, fmt.Errorf("unable to count members of channel %s: %s", joinableChannel.ChannelID, err)
+	}
+
+	return counts[joinableChannel.RoleID], nil
+}
+
+// refreshJoinEmbed updates the join embed of a channel after its capacity or number of members changed
+func refreshJoinEmbed(guildInfo *m.GuildInformation, channel *discordgo.Channel, joinableChannel *m.JoinableChannel) error {
+	if joinableChannel.EmbedMessageID == "" {
+		return nil
+	}
+
+	group, err := channelGroup(guildInfo, joinableChannel.GroupName)
+	if err != nil {
+		return err
+	}
+
+	members, err := channelMembers(guildInfo, joinableChannel)
+	if err != nil {
+		return err
+	}
+
+	return c.Messages.EditJoinableChannelEmbed(group.JoinChannelID, joinableChannel.EmbedMessageID, channel, members, joinableChannel.Capacity)
+}
+
+// admitChannelMember adds a user to a channel, or to its waitlist when the channel is full.
+// It returns the outcome for the user.
+func admitChannelMember(guildInfo *m.GuildInformation, user *discordgo.User, channel *discordgo.Channel, joinableChannel *m.JoinableChannel) (string, error) {
+	if joinableChannel.Capacity <= 0 {
+		err := addChannelMember(guildInfo, user, channel, joinableChannel)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("You joined %s.", channel.Mention()), nil
+	}
+
+	lock := admissionLock(channel.ID)
+	lock.Lock()
+
+	members, err := channelMembers(guildInfo, joinableChannel)
+	if err != nil {
+		lock.Unlock()
+		return "", err
+	}
+
+	if members >= joinableChannel.Capacity {
+		lock.Unlock()
+		return joinWaitlist(guildInfo, user, channel)
+	}
+
+	err = addChannelMember(guildInfo, user, channel, joinableChannel)
+	lock.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	err = refreshJoinEmbed(guildInfo, channel, joinableChannel)
+	if err != nil {
+		logger.Warnf("unable to update join embed of channel %s: %s", channel.Name, err)
+	}
+
+	return fmt.Sprintf("You joined %s.", channel.Mention()), nil
+}
+
+// joinWaitlist puts a user at the end of the waitlist of a full channel
+func joinWaitlist(guildInfo *m.GuildInformation, user *discordgo.User, channel *discordgo.Channel) (string, error) {
+	waitlist, err := c.DataStore.GetWaitlist(channel.ID)
+	if err != nil {
+		return "", err
+	}
+
+	for position, entry := range waitlist {
+		if entry.UserID == user.ID {
+			return fmt.Sprintf("%s is still full. You are number %d on the waitlist.", channel.Mention(), position+1), nil
+		}
+	}
+
+	err = c.DataStore.CreateWaitlistEntry(m.WaitlistEntry{
+		GuildID:   guildInfo.GuildID,
+		ChannelID: channel.ID,
+		UserID:    user.ID,
+		JoinedAt:  time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s is full. You are number %d on the waitlist and join automatically when a spot opens up.", channel.Mention(), len(waitlist)+1), nil
+}
+
+// leaveWaitlist takes a user off the waitlist of a channel and reports whether they were on it
+func leaveWaitlist(channel *discordgo.Channel, user *discordgo.User) (bool, error) {
+	waitlist, err := c.DataStore.GetWaitlist(channel.ID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range waitlist {
+		if entry.UserID == user.ID {
+			return true, c.DataStore.DeleteWaitlistEntry(channel.ID, user.ID)
+		}
+	}
+
+	return false, nil
+}
+
+// promoteWaitlist fills the open spots of a channel with the users on its waitlist, first come first served
+func promoteWaitlist(guildInfo *m.GuildInformation, channel *discordgo.Channel, joinableChannel *m.JoinableChannel) {
+	waitlist, err := c.DataStore.GetWaitlist(channel.ID)
+	if err != nil {
+		logger.Errorf("unable to retrieve waitlist of channel %s: %s", channel.Name, err)
+		return
+	}
+
+	if len(waitlist) == 0 {
+		return
+	}
+
+	lock := admissionLock(channel.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	members, err := channelMembers(guildInfo, joinableChannel)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	for _, entry := range waitlist {
+		if joinableChannel.Capacity > 0 && members >= joinableChannel.Capacity {
+			break
+		}
+
+		err = c.DataStore.DeleteWaitlistEntry(channel.ID, entry.UserID)
+		if err != nil {
+			logger.Errorf("unable to remove user %s from the waitlist of channel %s: %s", entry.UserID, channel.Name, err)
+			continue
+		}
+
+		userRoles, err := c.Users.GetUserRoles(guildInfo.GuildID, entry.UserID)
+		if err != nil {
+			logger.Warnf("unable to promote user %s from the waitlist of channel %s: %s", entry.UserID, channel.Name, err)
+			continue
+		}
+
+		if _, found := h.FindRoleID(userRoles, joinableChannel.RoleID); found {
+			continue
+		}
+
+		user, err := discordClient.User(entry.UserID)
+		if err != nil {
+			logger.Warnf("unable to promote user %s from the waitlist of channel %s: %s", entry.UserID, channel.Name, err)
+			continue
+		}
+
+		err = addChannelMember(guildInfo, user, channel, joinableChannel)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		members++
+
+		err = c.Messages.SendDirectMessage(user.ID, fmt.Sprintf("A spot opened up in #%s, you joined it from the waitlist.", channel.Name))
+		if err != nil {
+			logger.Warnf("unable to notify user %s of joining channel %s: %s", user.ID, channel.Name, err)
+		}
+	}
+}
+
+func channelCapacity(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name string
+	var capacity int
+
+	guildInfo, err := checkGuildSetup(i.GuildID)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	permitted := h.PermissionChecker(guildInfo, i)
+	if !permitted {
+		h.SendInteractionResponse(s, i, h.InsufficientPermissions)
+		return
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "channelname":
+			name = option.StringValue()
+		case "capacity":
+			capacity = int(option.IntValue())
+		default:
+			h.SendInteractionResponse(s, i, h.UnknownOption)
+			return
+		}
+	}
+
+	channel, joinableChannel, err := joinableChannelByName(s, i.GuildID, name)
+	if err != nil {
+		h.SendInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	// counting the members and promoting users from the waitlist takes a while
+	err = h.SendInteractionAwaitResponse(s, i, "")
+	if err != nil {
+		logger.Errorf("unable to send response to guild: %s", err)
+		return
+	}
+
+	joinableChannel.Capacity = capacity
+
+	err = c.DataStore.CreateJoinableChannel(*joinableChannel)
+	if err != nil {
+		h.EditInteractionResponse(s, i, err.Error())
+		return
+	}
+
+	promoteWaitlist(guildInfo, channel, joinableChannel)
+
+	err = refreshJoinEmbed(guildInfo, channel, joinableChannel)
+	if err != nil {
+		logger.Warnf("unable to update join embed of channel %s: %s", channel.Name, err)
+	}
+
+	if capacity == 0 {
+		h.EditInteractionResponse(s, i, fmt.Sprintf("%s no longer has a capacity.", channel.Mention()))
+		return
+	}
+
+	h.EditInteractionResponse(s, i, fmt.Sprintf("%s now has a capacity of %d members.", channel.Mention(), capacity))
+}
@@ -0,0 +1,66 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package hirohito
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// worker runs a task at a fixed interval until its context is cancelled.
+// The clock is injectable so the task can be driven with any point in time.
+type worker struct {
+	name     string
+	interval time.Duration
+	clock    func() time.Time
+	task     func(now time.Time)
+}
+
+func (w worker) run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	logger.Infof("starting %s worker", w.name)
+
+	// run once at startup instead of waiting for the first tick
+	w.task(w.clock())
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infof("stopping %s worker", w.name)
+			return
+		case <-ticker.C:
+			w.task(w.clock())
+		}
+	}
+}
+
+func startWorker(ctx context.Context, wg *sync.WaitGroup, w worker) {
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		w.run(ctx)
+	}()
+}
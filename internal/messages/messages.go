@@ -23,6 +23,9 @@ package messages
 
 import (
 	"fmt"
+	h "hirohito/internal/helpers"
+	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -40,18 +43,50 @@ func MessagesConstructor(client *discordgo.Session) *Messages {
 	}
 }
 
-func (m Messages) UserJoinedChannelMessage(guildID string, channelID string, user discordgo.User) {
-	message := fmt.Sprintf("▶️ User %s joined the channel!", user.Mention())
-	m.discordClient.ChannelMessageSend(channelID, message)
+// Placeholders of the join and leave announcement templates
+const (
+	PlaceholderUser     = "{user}"
+	PlaceholderUsername = "{username}"
+	PlaceholderChannel  = "{channel}"
+	PlaceholderMembers  = "{members}"
+)
+
+// announcement fills in the placeholders of a join or leave template
+func announcement(template string, user discordgo.User, channel *discordgo.Channel, members int) string {
+	return strings.NewReplacer(
+		PlaceholderUser, user.Mention(),
+		PlaceholderUsername, user.Username,
+		PlaceholderChannel, channel.Mention(),
+		PlaceholderMembers, fmt.Sprint(members),
+	).Replace(template)
+}
+
+// UserJoinedChannelMessage announces in targetChannelID that a user joined a channel, using a template
+func (m Messages) UserJoinedChannelMessage(targetChannelID, template string, user discordgo.User, channel *discordgo.Channel, members int) error {
+	return m.SendMessage(targetChannelID, announcement(template, user, channel, members))
 }
 
-func (m Messages) UserLeftChannelMessage(guildID string, channelID string, user discordgo.User) {
-	message := fmt.Sprintf("🚮 User %s left the channel!", user.Mention())
-	m.discordClient.ChannelMessageSend(channelID, message)
+// UserLeftChannelMessage announces in targetChannelID that a user left a channel, using a template
+func (m Messages) UserLeftChannelMessage(targetChannelID, template string, user discordgo.User, channel *discordgo.Channel, members int) error {
+	return m.SendMessage(targetChannelID, announcement(template, user, channel, members))
 }
 
-func (m Messages) JoinableChannelEmbed(guildID string, messageChannel string, channel *discordgo.Channel) error {
+func channelTypeName(channelType discordgo.ChannelType) string {
+	switch channelType {
+	case discordgo.ChannelTypeGuildVoice:
+		return "🔊 Voice channel"
+	case discordgo.ChannelTypeGuildStageVoice:
+		return "🎙️ Stage channel"
+	case discordgo.ChannelTypeGuildForum:
+		return "💬 Forum"
+	default:
+		return "#️⃣ Text channel"
+	}
+}
 
+// joinableChannelEmbed builds the join embed of a channel. The number of members is only shown
+// for channels with a capacity, a capacity of 0 means the channel has no limit.
+func joinableChannelEmbed(channel *discordgo.Channel, members, capacity int) *discordgo.MessageEmbed {
 	embed := discordgo.MessageEmbed{
 		Title:       fmt.Sprintf(`Joinable channel "%s"`, channel.Name),
 		Type:        discordgo.EmbedTypeRich,
@@ -63,28 +98,376 @@ func (m Messages) JoinableChannelEmbed(guildID string, messageChannel string, ch
 				Inline: false,
 			},
 			{
-				Name:   "Use the reactions below to join or leave the channel",
+				Name:   "Channel type",
+				Value:  channelTypeName(channel.Type),
 				Inline: false,
 			},
 		},
 	}
 
-	message, err := m.discordClient.ChannelMessageSendEmbed(messageChannel, &embed)
+	if capacity > 0 {
+		status := fmt.Sprintf("%d/%d", members, capacity)
+		if members >= capacity {
+			status += ", new members go on the waitlist"
+		}
+
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Members",
+			Value:  status,
+			Inline: false,
+		})
+	}
+
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:   "Use the buttons below to join or leave the channel",
+		Inline: false,
+	})
+
+	return &embed
+}
+
+func joinableChannelComponents(channelID string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Join",
+					Style:    discordgo.SuccessButton,
+					CustomID: h.ComponentID("joinchannel", channelID),
+					Emoji: discordgo.ComponentEmoji{
+						Name: "▶️",
+					},
+				},
+				discordgo.Button{
+					Label:    "Leave",
+					Style:    discordgo.SecondaryButton,
+					CustomID: h.ComponentID("leavechannel", channelID),
+					Emoji: discordgo.ComponentEmoji{
+						Name: "🚮",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (m Messages) JoinableChannelEmbed(guildID string, messageChannel string, channel *discordgo.Channel, members, capacity int) (*discordgo.Message, error) {
+	return m.discordClient.ChannelMessageSendComplex(messageChannel, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{joinableChannelEmbed(channel, members, capacity)},
+		Components: joinableChannelComponents(channel.ID),
+	})
+}
+
+// EditJoinableChannelEmbed updates a join embed after its channel or the number of members changed
+func (m Messages) EditJoinableChannelEmbed(messageChannel, messageID string, channel *discordgo.Channel, members, capacity int) error {
+	edit := discordgo.NewMessageEdit(messageChannel, messageID)
+	edit.Embeds = []*discordgo.MessageEmbed{joinableChannelEmbed(channel, members, capacity)}
+	edit.Components = joinableChannelComponents(channel.ID)
+
+	_, err := m.discordClient.ChannelMessageEditComplex(edit)
+	return err
+}
+
+// ConvertJoinableChannelEmbed replaces the reactions of a join embed made by older versions with buttons
+func (m Messages) ConvertJoinableChannelEmbed(message *discordgo.Message, channel *discordgo.Channel, members, capacity int) error {
+	edit := discordgo.NewMessageEdit(message.ChannelID, message.ID)
+	edit.Embeds = []*discordgo.MessageEmbed{joinableChannelEmbed(channel, members, capacity)}
+	edit.Components = joinableChannelComponents(channel.ID)
+
+	_, err := m.discordClient.ChannelMessageEditComplex(edit)
 	if err != nil {
 		return err
 	}
 
-	err = m.discordClient.MessageReactionAdd(message.ChannelID, message.ID, "▶️")
+	return m.discordClient.MessageReactionsRemoveAll(message.ChannelID, message.ID)
+}
+
+// Discord allows 5 select menus of 25 options per message
+const (
+	directoryMenus       = 5
+	directoryMenuOptions = 25
+
+	// DirectoryCapacity is the number of channels that fit in a channel directory message
+	DirectoryCapacity = directoryMenus * directoryMenuOptions
+)
+
+// channelDirectoryMessage builds the directory of joinable channels. Channels beyond DirectoryCapacity are left out.
+func channelDirectoryMessage(channels []*discordgo.Channel) (*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	var components []discordgo.MessageComponent
+
+	embed := discordgo.MessageEmbed{
+		Title:       "Channel directory",
+		Type:        discordgo.EmbedTypeRich,
+		Description: "Select channels to join them. You then get a menu only you can see, in which you can also leave channels.",
+	}
+
+	if len(channels) == 0 {
+		embed.Description = "There are no joinable channels yet."
+	}
+
+	for start := 0; start < len(channels) && len(components) < directoryMenus; start += directoryMenuOptions {
+		end := start + directoryMenuOptions
+		if end > len(channels) {
+			end = len(channels)
+		}
+
+		var options []discordgo.SelectMenuOption
+		for _, channel := range channels[start:end] {
+			options = append(options, discordgo.SelectMenuOption{
+				Label:       channel.Name,
+				Value:       channel.ID,
+				Description: h.Truncate(channel.Topic, 100),
+			})
+		}
+
+		minValues := 0
+		components = append(components, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					CustomID:    h.ComponentID("directory", fmt.Sprint(len(components))),
+					Placeholder: fmt.Sprintf("Joinable channels %s - %s", channels[start].Name, channels[end-1].Name),
+					MinValues:   &minValues,
+					MaxValues:   len(options),
+					Options:     options,
+				},
+			},
+		})
+	}
+
+	return &embed, components
+}
+
+func (m Messages) ChannelDirectoryMessage(channelID string, channels []*discordgo.Channel) (*discordgo.Message, error) {
+	embed, components := channelDirectoryMessage(channels)
+
+	message, err := m.discordClient.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.discordClient.ChannelMessagePin(channelID, message.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+func (m Messages) EditChannelDirectory(channelID, messageID string, channels []*discordgo.Channel) error {
+	embed, components := channelDirectoryMessage(channels)
+
+	edit := discordgo.NewMessageEdit(channelID, messageID)
+	edit.Embeds = []*discordgo.MessageEmbed{embed}
+	edit.Components = components
+
+	_, err := m.discordClient.ChannelMessageEditComplex(edit)
+	return err
+}
+
+func (m Messages) ArchiveWarningMessage(channelID string, days int) (*discordgo.Message, error) {
+	message := discordgo.MessageSend{
+		Content: fmt.Sprintf("📦 This channel has been inactive for a while and will be archived in %d days. Press the button below to keep it.", days),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Keep this channel",
+						Style:    discordgo.PrimaryButton,
+						CustomID: h.ComponentID("keepchannel", channelID),
+					},
+				},
+			},
+		},
+	}
+
+	return m.discordClient.ChannelMessageSendComplex(channelID, &message)
+}
+
+// ExpiryReminderMessage posts a reminder in a channel that it expires soon, using discord's timestamp formatting
+func (m Messages) ExpiryReminderMessage(channelID string, expiresAt time.Time, archive bool) error {
+	action := "deleted"
+	if archive {
+		action = "archived"
+	}
+
+	return m.SendMessage(channelID, fmt.Sprintf("⏳ This channel expires <t:%d:R> and will be %s <t:%d:f>.", expiresAt.Unix(), action, expiresAt.Unix()))
+}
+
+// JoinRequestMessage posts a request to join a channel that needs approval, with buttons to approve or deny it
+func (m Messages) JoinRequestMessage(adminChannelID string, user *discordgo.User, channel *discordgo.Channel) (*discordgo.Message, error) {
+	message := discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Title:       "Join request",
+				Type:        discordgo.EmbedTypeRich,
+				Description: fmt.Sprintf("%s wants to join %s.", user.Mention(), channel.Mention()),
+			},
+		},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Approve",
+						Style:    discordgo.SuccessButton,
+						CustomID: h.ComponentID("joinrequest", "approve"),
+					},
+					discordgo.Button{
+						Label:    "Deny",
+						Style:    discordgo.DangerButton,
+						CustomID: h.ComponentID("joinrequest", "deny"),
+					},
+				},
+			},
+		},
+	}
+
+	return m.discordClient.ChannelMessageSendComplex(adminChannelID, &message)
+}
+
+// DecideJoinRequest records the decision on a join request in its message and removes the buttons
+func (m Messages) DecideJoinRequest(message *discordgo.Message, decision string) error {
+	return m.closeMessage(message, "Decision", decision)
+}
+
+// closeMessage adds a final field to the embed of a message and removes its buttons
+func (m Messages) closeMessage(message *discordgo.Message, name, value string) error {
+	edit := discordgo.NewMessageEdit(message.ChannelID, message.ID)
+	edit.Components = []discordgo.MessageComponent{}
+
+	if len(message.Embeds) > 0 {
+		embed := *message.Embeds[0]
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  name,
+			Value: value,
+		})
+		edit.Embeds = []*discordgo.MessageEmbed{&embed}
+	}
+
+	_, err := m.discordClient.ChannelMessageEditComplex(edit)
+	return err
+}
+
+func channelProposalEmbed(name, topic, proposerID string, votes, threshold int, closesAt time.Time) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Channel proposal: #%s", name),
+		Type:        discordgo.EmbedTypeRich,
+		Description: topic,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Proposed by",
+				Value:  fmt.Sprintf("<@%s>", proposerID),
+				Inline: true,
+			},
+			{
+				Name:   "Votes",
+				Value:  fmt.Sprintf("%d/%d", votes, threshold),
+				Inline: true,
+			},
+			{
+				Name:   "Voting closes",
+				Value:  fmt.Sprintf("<t:%d:R>", closesAt.Unix()),
+				Inline: true,
+			},
+		},
+	}
+}
+
+// ChannelProposalMessage posts a proposal for a new joinable channel with a button to upvote it
+func (m Messages) ChannelProposalMessage(channelID, name, topic, proposerID string, threshold int, closesAt time.Time) (*discordgo.Message, error) {
+	message := discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{channelProposalEmbed(name, topic, proposerID, 0, threshold, closesAt)},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "👍 Upvote",
+						Style:    discordgo.PrimaryButton,
+						CustomID: h.ComponentID("proposal", "upvote"),
+					},
+				},
+			},
+		},
+	}
+
+	return m.discordClient.ChannelMessageSendComplex(channelID, &message)
+}
+
+// EditChannelProposalMessage updates the vote count of a proposal
+func (m Messages) EditChannelProposalMessage(channelID, messageID, name, topic, proposerID string, votes, threshold int, closesAt time.Time) error {
+	edit := discordgo.NewMessageEdit(channelID, messageID)
+	edit.Embeds = []*discordgo.MessageEmbed{channelProposalEmbed(name, topic, proposerID, votes, threshold, closesAt)}
+
+	_, err := m.discordClient.ChannelMessageEditComplex(edit)
+	return err
+}
+
+// ProposalVetoMessage posts a copy of a proposal in the admin channel with a button to veto it
+func (m Messages) ProposalVetoMessage(adminChannelID string, proposal *discordgo.Message, guildID, name, topic, proposerID string) (*discordgo.Message, error) {
+	message := discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Title:       "Channel proposal",
+				Type:        discordgo.EmbedTypeRich,
+				Description: fmt.Sprintf("<@%s> proposed #%s: %s", proposerID, name, topic),
+				Fields: []*discordgo.MessageEmbedField{
+					{
+						Name:  "Proposal",
+						Value: fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, proposal.ChannelID, proposal.ID),
+					},
+				},
+			},
+		},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Veto",
+						Style:    discordgo.DangerButton,
+						CustomID: h.ComponentID("proposal", "veto"),
+					},
+				},
+			},
+		},
+	}
+
+	return m.discordClient.ChannelMessageSendComplex(adminChannelID, &message)
+}
+
+// CloseChannelProposal records the outcome of a proposal in its message and removes the buttons
+func (m Messages) CloseChannelProposal(channelID, messageID, outcome string) error {
+	message, err := m.discordClient.ChannelMessage(channelID, messageID)
 	if err != nil {
 		return err
 	}
 
-	err = m.discordClient.MessageReactionAdd(message.ChannelID, message.ID, "🚮")
+	return m.closeMessage(message, "Outcome", outcome)
+}
+
+func (m Messages) SendDirectMessage(userID, message string) error {
+	channel, err := m.discordClient.UserChannelCreate(userID)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	_, err = m.discordClient.ChannelMessageSend(channel.ID, message)
+	return err
+}
+
+func (m Messages) SendMessage(channelID, message string) error {
+	_, err := m.discordClient.ChannelMessageSend(channelID, message)
+	return err
+}
+
+func (m Messages) SendFiles(channelID, content string, files []*discordgo.File) error {
+	_, err := m.discordClient.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content: content,
+		Files:   files,
+	})
+
+	return err
 }
 
 func (m Messages) GetMessagesInChannel(channelID string) ([]*discordgo.Message, error) {
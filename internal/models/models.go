@@ -23,6 +23,7 @@ package models
 
 import (
 	"database/sql"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -30,9 +31,10 @@ import (
 )
 
 type Config struct {
-	Global    GlobalConfig
-	Discord   DiscordConfig
-	DataStore DataStoreConfig
+	Global      GlobalConfig
+	Discord     DiscordConfig
+	DataStore   DataStoreConfig
+	Transcripts TranscriptConfig
 }
 
 type GlobalConfig struct {
@@ -47,6 +49,10 @@ type DataStoreConfig struct {
 	Client *sql.DB
 }
 
+type TranscriptConfig struct {
+	Path string // Directory transcripts are written to. Transcripts are posted in the admin channel when empty
+}
+
 type GuildInformation struct {
 	GuildID                    string
 	JoinChannelID              string
@@ -57,9 +63,115 @@ type GuildInformation struct {
 	ModeratorRoleID            string
 }
 
+type JoinableChannel struct {
+	GuildID          string
+	ChannelID        string
+	RoleID           string
+	EmbedMessageID   string // Empty when the channel has no join embed, e.g. in directory mode or when archived
+	CreatorID        string
+	CreatedAt        time.Time
+	GroupName        string    // Empty for channels of the group configured with the setup command
+	RequiresApproval int       // 0 == false, 1 == true
+	Capacity         int       // Maximum number of members. 0 == unlimited
+	ExpiresAt        time.Time // Zero for channels that do not expire
+	ExpiryArchive    int       // 0 == delete on expiry, 1 == archive on expiry
+	ExpiryReminded   int       // 0 == false, 1 == true
+}
+
+type WaitlistEntry struct {
+	GuildID   string
+	ChannelID string
+	UserID    string
+	JoinedAt  time.Time
+}
+
+type JoinRequest struct {
+	GuildID     string
+	ChannelID   string
+	UserID      string
+	MessageID   string // Request with the approve and deny buttons in the admin channel
+	RequestedAt time.Time
+}
+
+type JoinPrerequisites struct {
+	GuildID         string
+	ChannelID       string
+	RequiredRoleIDs []string // Roles a user needs all of to join
+	MinAccountAge   int      // Days since the account was created. 0 == no minimum
+	MinMemberAge    int      // Days since the user joined the guild. 0 == no minimum
+}
+
+type AnnouncementSettings struct {
+	GuildID       string
+	ChannelID     string // Empty for the settings of the guild, set for the override of a channel
+	Mode          string // "channel", "log" or "off". Empty in an override uses the mode of the guild
+	LogChannelID  string // Channel announcements are sent to in log mode
+	JoinTemplate  string // Empty uses the template of the guild, or the default one
+	LeaveTemplate string // Empty uses the template of the guild, or the default one
+}
+
+type ChannelProposal struct {
+	GuildID        string
+	ChannelID      string // Channel the proposal was posted in
+	MessageID      string // Proposal with the upvote button
+	AdminMessageID string // Copy with the veto button in the admin channel
+	Name           string
+	Topic          string
+	ProposerID     string
+	ProposedAt     time.Time
+}
+
+type ProposalSettings struct {
+	GuildID   string
+	Threshold int // Votes needed for a proposed channel to be created
+	Window    int // Days a proposal is open for votes
+}
+
+type ChannelDirectory struct {
+	GuildID   string
+	ChannelID string
+	MessageID string
+}
+
+type ChannelGroup struct {
+	GuildID            string
+	Name               string
+	JoinChannelID      string
+	DirectoryMessageID string // Directory of the group in directory mode, empty otherwise
+}
+
+type GroupCategory struct {
+	GuildID    string
+	GroupName  string
+	CategoryID string
+}
+
 type ArchivingInformation struct {
 	GuildID             string
 	Auto                int // 0 == false, 1 == true
 	Interval            int // Interval in days between check and last channel message
 	ArchivingCategoryID string
+	WarningDays         int // Days before archiving that a warning is posted in the channel. 0 disables warnings
+	Retention           int // Days an archived channel is kept before it is purged. 0 keeps archived channels forever
+}
+
+type ArchivedChannel struct {
+	GuildID    string
+	ChannelID  string
+	ArchivedAt time.Time
+}
+
+type ArchiveOverride struct {
+	GuildID   string
+	ChannelID string
+	Exempt    int // 0 == false, 1 == true
+	Interval  int // Interval in days overriding the guild interval. 0 uses the guild interval
+}
+
+type ArchiveWarning struct {
+	GuildID      string
+	ChannelID    string
+	MessageID    string
+	LastActivity time.Time // Last activity in the channel when the warning was posted
+	WarnedAt     time.Time
 }
@@ -73,6 +73,20 @@ func (r Roles) CreateRole(guildID string, data *discordgo.RoleParams) (*discordg
 	return role, nil
 }
 
+func (r Roles) RenameRole(guildID, roleID, name string) error {
+	len := len(name)
+	if len < 2 || len > 100 {
+		return fmt.Errorf("role name length must be between 2 and 100 characters. current length: %d", len)
+	}
+
+	_, err := r.discordClient.GuildRoleEdit(guildID, roleID, &discordgo.RoleParams{Name: name})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (r Roles) DeleteRole(guildID, roleID string) error {
 	err := r.discordClient.GuildRoleDelete(guildID, roleID)
 	if err != nil {
@@ -0,0 +1,158 @@
+/*
+Copyright (c) 2023 Ian Hulsbus
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+the Software, and to permit persons to whom the Software is furnished to do so,
+subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package transcripts
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"sort"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type Transcript struct {
+	GuildID     string    `json:"guildId"`
+	ChannelID   string    `json:"channelId"`
+	ChannelName string    `json:"channelName"`
+	Topic       string    `json:"topic"`
+	ExportedAt  time.Time `json:"exportedAt"`
+	Messages    []Message `json:"messages"`
+}
+
+type Message struct {
+	ID          string       `json:"id"`
+	Thread      string       `json:"thread,omitempty"`
+	AuthorID    string       `json:"authorId"`
+	Author      string       `json:"author"`
+	Content     string       `json:"content"`
+	Timestamp   time.Time    `json:"timestamp"`
+	Edited      *time.Time   `json:"edited,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Embeds      []Embed      `json:"embeds,omitempty"`
+}
+
+type Attachment struct {
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+}
+
+type Embed struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>#{{.ChannelName}}</title>
+<style>
+body { font-family: sans-serif; background: #313338; color: #dbdee1; margin: 2em; }
+header { border-bottom: 1px solid #4e5058; margin-bottom: 1em; }
+.message { margin: 0.75em 0; }
+.author { font-weight: bold; color: #f2f3f5; }
+.time { color: #949ba4; font-size: 0.8em; margin-left: 0.5em; }
+.content { white-space: pre-wrap; }
+.embed { border-left: 4px solid #5865f2; padding: 0.25em 0.75em; margin-top: 0.25em; background: #2b2d31; }
+a { color: #00a8fc; }
+</style>
+</head>
+<body>
+<header>
+<h1>#{{.ChannelName}}</h1>
+<p>{{.Topic}}</p>
+<p>Exported {{.ExportedAt.Format "2006-01-02 15:04 MST"}}, {{len .Messages}} messages</p>
+</header>
+{{range .Messages}}<div class="message">
+<span class="author">{{.Author}}</span><span class="time">{{.Timestamp.Format "2006-01-02 15:04"}}{{if .Edited}} (edited){{end}}{{if .Thread}} in {{.Thread}}{{end}}</span>
+<div class="content">{{.Content}}</div>
+{{range .Attachments}}<div><a href="{{.URL}}">{{.Filename}}</a></div>
+{{end}}{{range .Embeds}}<div class="embed"><strong>{{.Title}}</strong><div class="content">{{.Description}}</div></div>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// New builds a transcript of a channel. The messages are sorted oldest first,
+// regardless of the order they were retrieved in. Threads maps the IDs of the threads
+// the messages were posted in, such as the posts of a forum, to their names.
+func New(channel *discordgo.Channel, messages []*discordgo.Message, threads map[string]string, exportedAt time.Time) *Transcript {
+	transcript := Transcript{
+		GuildID:     channel.GuildID,
+		ChannelID:   channel.ID,
+		ChannelName: channel.Name,
+		Topic:       channel.Topic,
+		ExportedAt:  exportedAt,
+		Messages:    make([]Message, 0, len(messages)),
+	}
+
+	for _, message := range messages {
+		entry := Message{
+			ID:        message.ID,
+			Thread:    threads[message.ChannelID],
+			Content:   message.Content,
+			Timestamp: message.Timestamp,
+			Edited:    message.EditedTimestamp,
+		}
+
+		if message.Author != nil {
+			entry.AuthorID = message.Author.ID
+			entry.Author = message.Author.Username
+			if message.Author.Discriminator != "" && message.Author.Discriminator != "0" {
+				entry.Author = message.Author.String()
+			}
+		}
+
+		for _, attachment := range message.Attachments {
+			entry.Attachments = append(entry.Attachments, Attachment{Filename: attachment.Filename, URL: attachment.URL})
+		}
+
+		for _, embed := range message.Embeds {
+			entry.Embeds = append(entry.Embeds, Embed{Title: embed.Title, Description: embed.Description})
+		}
+
+		transcript.Messages = append(transcript.Messages, entry)
+	}
+
+	sort.SliceStable(transcript.Messages, func(i, j int) bool {
+		return transcript.Messages[i].Timestamp.Before(transcript.Messages[j].Timestamp)
+	})
+
+	return &transcript
+}
+
+func (t Transcript) JSON() ([]byte, error) {
+	return json.MarshalIndent(t, "", "  ")
+}
+
+// HTML renders the transcript as a single page without external resources
+func (t Transcript) HTML() ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := htmlTemplate.Execute(&buf, t); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
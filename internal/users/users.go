@@ -65,3 +65,46 @@ func (u Users) GetUserRoles(guildID, userID string) ([]string, error) {
 
 	return user.Roles, nil
 }
+
+func (u Users) GetGuildMember(guildID, userID string) (*discordgo.Member, error) {
+	return u.discordClient.GuildMember(guildID, userID)
+}
+
+func (u Users) GetGuildMembers(guildID string) ([]*discordgo.Member, error) {
+	var guildMembers []*discordgo.Member
+	var afterID string
+
+	for {
+		members, err := u.discordClient.GuildMembers(guildID, afterID, 1000)
+		if err != nil {
+			return nil, err
+		}
+
+		guildMembers = append(guildMembers, members...)
+
+		if len(members) == 1000 {
+			afterID = members[999].User.ID
+		} else {
+			break
+		}
+	}
+
+	return guildMembers, nil
+}
+
+// CountRoleMembers returns the number of members holding each role
+func (u Users) CountRoleMembers(guildID string) (map[string]int, error) {
+	members, err := u.GetGuildMembers(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, member := range members {
+		for _, roleID := range member.Roles {
+			counts[roleID]++
+		}
+	}
+
+	return counts, nil
+}